@@ -0,0 +1,107 @@
+package trip
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff determines the delay inbetween attempts of a retried request.
+type Backoff interface {
+	// Next returns the delay before the next attempt, given the number of
+	// attempts made so far, starting at 1.
+	Next(attempt int) time.Duration
+}
+
+// BackoffFunc implements Backoff for convenient usage.
+type BackoffFunc func(attempt int) time.Duration
+
+// Next satisfies Backoff and calls fn.
+func (fn BackoffFunc) Next(attempt int) time.Duration {
+	return fn(attempt)
+}
+
+// ConstantBackoff waits the same delay after every attempt.
+func ConstantBackoff(delay time.Duration) Backoff {
+	return BackoffFunc(func(int) time.Duration {
+		return delay
+	})
+}
+
+// ExponentialBackoff doubles the delay after every attempt, starting at base
+// and never exceeding max.
+//
+// Delays: base, base*2, base*4, ... max
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt int) time.Duration {
+		return exponential(base, max, attempt)
+	})
+}
+
+// FullJitterBackoff waits a random delay between zero and the delay an
+// ExponentialBackoff would wait, spreading retries of concurrent requests.
+func FullJitterBackoff(base, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt int) time.Duration {
+		d := exponential(base, max, attempt)
+		if d <= 0 {
+			return 0
+		}
+		return time.Duration(randInt63n(int64(d) + 1))
+	})
+}
+
+// DecorrelatedJitterBackoff waits a random delay between base and three times the
+// previous delay, never exceeding max. The delay sequence restarts at base with the
+// first attempt of a request. As the previous delay is shared, concurrent requests
+// influence each others delays.
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+
+	// Rand is the source of randomness. If nil, the source set by SetRandReader is used.
+	Rand *rand.Rand
+
+	mu   sync.Mutex
+	prev time.Duration
+}
+
+// DecorrelatedJitter creates a new DecorrelatedJitterBackoff.
+func DecorrelatedJitter(base, max time.Duration) *DecorrelatedJitterBackoff {
+	return &DecorrelatedJitterBackoff{Base: base, Max: max}
+}
+
+// Next satisfies Backoff.
+func (b *DecorrelatedJitterBackoff) Next(attempt int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if attempt <= 1 || b.prev < b.Base {
+		b.prev = b.Base
+	}
+
+	d := b.Base
+	if spread := int64(b.prev*3 - b.Base); spread > 0 {
+		if b.Rand != nil {
+			d += time.Duration(b.Rand.Int63n(spread + 1))
+		} else {
+			d += time.Duration(randInt63n(spread + 1))
+		}
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+
+	b.prev = d
+	return d
+}
+
+func exponential(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
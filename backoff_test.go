@@ -0,0 +1,101 @@
+package trip_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	b := trip.ConstantBackoff(10 * time.Millisecond)
+
+	for attempt := 1; attempt <= 5; attempt++ {
+		assertEqual(t, b.Next(attempt), 10*time.Millisecond)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	var (
+		b        = trip.ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+		expected = []time.Duration{
+			10 * time.Millisecond,
+			20 * time.Millisecond,
+			40 * time.Millisecond,
+			50 * time.Millisecond,
+			50 * time.Millisecond,
+		}
+	)
+
+	for i, delay := range expected {
+		assertEqual(t, b.Next(i+1), delay)
+	}
+}
+
+func TestExponentialBackoffLargeAttempt(t *testing.T) {
+	b := trip.ExponentialBackoff(time.Second, time.Minute)
+	assertEqual(t, b.Next(1000), time.Minute)
+}
+
+func TestFullJitterBackoff(t *testing.T) {
+	var (
+		b      = trip.FullJitterBackoff(10*time.Millisecond, 50*time.Millisecond)
+		limits = []time.Duration{
+			10 * time.Millisecond,
+			20 * time.Millisecond,
+			40 * time.Millisecond,
+			50 * time.Millisecond,
+		}
+	)
+
+	for i, limit := range limits {
+		for j := 0; j < 100; j++ {
+			if delay := b.Next(i + 1); delay < 0 || delay > limit {
+				t.Errorf("got: %v, expected: between 0 and %v", delay, limit)
+			}
+		}
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	var (
+		base  = 10 * time.Millisecond
+		max   = time.Second
+		b     = trip.DecorrelatedJitter(base, max)
+		first time.Duration
+		last  time.Duration
+		runs  = 1000
+	)
+	b.Rand = rand.New(rand.NewSource(1))
+
+	for i := 0; i < runs; i++ {
+		for attempt := 1; attempt <= 5; attempt++ {
+			delay := b.Next(attempt)
+			if delay < base || delay > max {
+				t.Fatalf("got: %v, expected: between %v and %v", delay, base, max)
+			}
+			if attempt == 1 {
+				first += delay
+			}
+			if attempt == 5 {
+				last += delay
+			}
+		}
+	}
+
+	if first/time.Duration(runs) >= last/time.Duration(runs) {
+		t.Errorf("got: average first delay %v, average last delay %v, expected growth", first/time.Duration(runs), last/time.Duration(runs))
+	}
+}
+
+func TestDecorrelatedJitterSeed(t *testing.T) {
+	a := trip.DecorrelatedJitter(10*time.Millisecond, time.Second)
+	a.Rand = rand.New(rand.NewSource(42))
+	b := trip.DecorrelatedJitter(10*time.Millisecond, time.Second)
+	b.Rand = rand.New(rand.NewSource(42))
+
+	for attempt := 1; attempt <= 5; attempt++ {
+		assertEqual(t, a.Next(attempt), b.Next(attempt))
+	}
+}
@@ -0,0 +1,54 @@
+package trip
+
+import (
+	"net/http"
+	"sync"
+)
+
+// WeightedHost is a host, optionally with port, and its weight for WeightedBalance.
+type WeightedHost struct {
+	Host   string
+	Weight int
+}
+
+// WeightedBalance distributes requests across hosts proportionally to their weights,
+// using the smooth weighted round-robin algorithm known from nginx. Requests to a
+// host of weight 3 are sent three times as often as to a host of weight 1, without
+// sending them in bursts. The URL host of every request is rewritten to the selected
+// host; the `Host` header is left untouched.
+func WeightedBalance(hosts []WeightedHost) TripFunc {
+	if len(hosts) == 0 {
+		panic("trip: no hosts to balance")
+	}
+	total := 0
+	for _, h := range hosts {
+		if h.Weight <= 0 {
+			panic("trip: host weight must be positive")
+		}
+		total += h.Weight
+	}
+	hosts = append([]WeightedHost(nil), hosts...)
+
+	var mu sync.Mutex
+	current := make([]int, len(hosts))
+	next := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		best := 0
+		for i, h := range hosts {
+			current[i] += h.Weight
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		return hosts[best].Host
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.URL.Host = next()
+			return t.RoundTrip(r)
+		})
+	}
+}
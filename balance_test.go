@@ -0,0 +1,58 @@
+package trip_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestWeightedBalance(t *testing.T) {
+	counts := map[string]int{}
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		counts[r.URL.Host]++
+		return nil, nil
+	}), trip.WeightedBalance([]trip.WeightedHost{
+		{Host: "a.example.com", Weight: 5},
+		{Host: "b.example.com", Weight: 3},
+		{Host: "c.example.com:8080", Weight: 2},
+	}))
+
+	const requests = 1000
+	for i := 0; i < requests; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		transport.RoundTrip(req)
+	}
+
+	expected := map[string]float64{"a.example.com": 0.5, "b.example.com": 0.3, "c.example.com:8080": 0.2}
+	for host, share := range expected {
+		got := float64(counts[host]) / requests
+		if got < share-0.01 || got > share+0.01 {
+			t.Errorf("got share %.3f for %s, expected %.3f", got, host, share)
+		}
+	}
+}
+
+func TestWeightedBalanceSmooth(t *testing.T) {
+	var hosts []string
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		hosts = append(hosts, r.URL.Host)
+		return nil, nil
+	}), trip.WeightedBalance([]trip.WeightedHost{{Host: "a", Weight: 2}, {Host: "b", Weight: 1}}))
+
+	for i := 0; i < 6; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		transport.RoundTrip(req)
+	}
+
+	for i, host := range []string{"a", "b", "a", "a", "b", "a"} {
+		assertEqual(t, hosts[i], host)
+	}
+}
+
+func TestWeightedBalanceInvalidWeight(t *testing.T) {
+	defer func() {
+		assertEqual(t, recover(), any("trip: host weight must be positive"))
+	}()
+	trip.WeightedBalance([]trip.WeightedHost{{Host: "a", Weight: 0}})
+}
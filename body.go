@@ -0,0 +1,333 @@
+package trip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrContentLengthMismatch is returned when reading a response body whose length doesn't
+// match the declared `Content-Length`.
+var ErrContentLengthMismatch = errors.New("trip: body length does not match Content-Length")
+
+// VerifyContentLength checks that response bodies are exactly as long as their declared
+// `Content-Length`. When the end of a body is reached with a different number of bytes
+// read, or more bytes than declared are read, reading fails with an error wrapping
+// ErrContentLengthMismatch. Responses without a known length are not checked.
+func VerifyContentLength() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := send(t, r)
+			if err != nil || resp.Body == nil || resp.ContentLength <= 0 {
+				return resp, err
+			}
+			resp.Body = &lengthVerifiedBody{ReadCloser: resp.Body, length: resp.ContentLength}
+			return resp, err
+		})
+	}
+}
+
+type lengthVerifiedBody struct {
+	io.ReadCloser
+	length int64
+	read   int64
+}
+
+func (b *lengthVerifiedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.length || err == io.EOF && b.read != b.length {
+		return n, fmt.Errorf("%w: declared %d bytes, read %d", ErrContentLengthMismatch, b.length, b.read)
+	}
+	return n, err
+}
+
+// ErrBodyReadTimeout is returned when reading a response body stalls for longer than
+// the timeout given to BodyReadTimeout.
+var ErrBodyReadTimeout = errors.New("trip: body read timed out")
+
+// BodyReadTimeout fails reading a response body with ErrBodyReadTimeout when no bytes
+// arrive within d. The timeout applies to every single read, so it detects stalled
+// streams regardless of their total duration, while slow consumers of the body are not
+// affected. The body is closed once the timeout is exceeded.
+func BodyReadTimeout(d time.Duration) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := send(t, r)
+			if err != nil || resp.Body == nil {
+				return resp, err
+			}
+			resp.Body = &timeoutBody{ReadCloser: resp.Body, timeout: d}
+			return resp, err
+		})
+	}
+}
+
+type timeoutBody struct {
+	io.ReadCloser
+	timeout  time.Duration
+	timedOut atomic.Bool
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	if b.timedOut.Load() {
+		return 0, ErrBodyReadTimeout
+	}
+	stop := clk.AfterFunc(b.timeout, func() {
+		b.timedOut.Store(true)
+		b.ReadCloser.Close()
+	})
+	n, err := b.ReadCloser.Read(p)
+	if !stop() && b.timedOut.Load() {
+		return n, ErrBodyReadTimeout
+	}
+	return n, err
+}
+
+type jsonBodyKey struct{}
+
+// WithJSONBody returns a copy of ctx carrying v, which is marshaled as JSON request
+// body by MarshalJSON.
+func WithJSONBody(ctx context.Context, v any) context.Context {
+	return context.WithValue(ctx, jsonBodyKey{}, v)
+}
+
+// MarshalJSON marshals the value stored with WithJSONBody in the request context as JSON
+// and sends it as the body of requests without a body. The `Content-Type` header is set
+// to `application/json` unless already present. As the body can be recreated through
+// GetBody, such requests can be retried.
+func MarshalJSON() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			v := r.Context().Value(jsonBodyKey{})
+			if v == nil || hasBody(r) {
+				return t.RoundTrip(r)
+			}
+
+			body, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+
+			setBody(r, body)
+			if r.Header.Get("Content-Type") == "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// BufferBody buffers request bodies of up to maxBytes in memory, so that they can be
+// replayed, e.g. by Retry. Trip functions that need to read the whole body, like
+// ContentDigest or SignEd25519, reuse the buffer instead of reading the body again.
+// Larger bodies are sent as-is and can't be replayed. A negative maxBytes buffers
+// bodies of any size. BufferBody should be placed after the trip functions relying on
+// it in the list of trip functions.
+func BufferBody(maxBytes int64) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if err := ensureReplayable(r, maxBytes); err != nil && err != errBodyTooLarge {
+				return nil, err
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// TransferEncoding controls how request bodies are framed. If chunked is true, bodies
+// are sent with chunked transfer encoding and without `Content-Length`, even if their
+// length is known. Otherwise, bodies of unknown length are buffered in memory to be
+// sent with a `Content-Length` header. Requests without a body are left untouched.
+func TransferEncoding(chunked bool) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if !hasBody(r) {
+				return t.RoundTrip(r)
+			}
+			if chunked {
+				r.TransferEncoding = []string{"chunked"}
+				r.ContentLength = 0
+				return t.RoundTrip(r)
+			}
+
+			r.TransferEncoding = nil
+			if r.ContentLength <= 0 {
+				body, err := bufferedBytes(r)
+				if err != nil {
+					return nil, err
+				}
+				r.ContentLength = int64(len(body))
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// errBodyTooLarge is returned by ensureReplayable for bodies exceeding the limit.
+var errBodyTooLarge = errors.New("trip: body too large to buffer")
+
+// bufferedBody is a request body buffered in memory by setBody.
+type bufferedBody struct {
+	*bytes.Reader
+	b []byte
+}
+
+func (*bufferedBody) Close() error { return nil }
+
+// ensureReplayable buffers the body of r in memory, if it isn't already, and allows it
+// to be recreated through GetBody. Bodies longer than maxBytes are left unbuffered, but
+// intact, and errBodyTooLarge is returned. A negative maxBytes buffers bodies of any
+// size.
+func ensureReplayable(r *http.Request, maxBytes int64) error {
+	if !hasBody(r) {
+		return nil
+	}
+	if b, ok := r.Body.(*bufferedBody); ok {
+		b.Seek(0, io.SeekStart)
+		return nil
+	}
+
+	src := io.Reader(r.Body)
+	if maxBytes >= 0 {
+		src = io.LimitReader(r.Body, maxBytes+1)
+	}
+	body, err := io.ReadAll(src)
+	if err != nil {
+		r.Body.Close()
+		return err
+	}
+	if maxBytes >= 0 && int64(len(body)) > maxBytes {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return errBodyTooLarge
+	}
+	r.Body.Close()
+	setBody(r, body)
+	return nil
+}
+
+// bufferedBytes buffers the body of r like ensureReplayable without a limit and
+// returns its content.
+func bufferedBytes(r *http.Request) ([]byte, error) {
+	if err := ensureReplayable(r, -1); err != nil {
+		return nil, err
+	}
+	if b, ok := r.Body.(*bufferedBody); ok {
+		return b.b, nil
+	}
+	return nil, nil
+}
+
+// setBody replaces the body of r with b and allows it to be recreated through GetBody.
+func setBody(r *http.Request, b []byte) {
+	r.Body = &bufferedBody{bytes.NewReader(b), b}
+	r.ContentLength = int64(len(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return &bufferedBody{bytes.NewReader(b), b}, nil
+	}
+}
+
+// TeeResponse writes the body of every response to w while it is read by the caller.
+// Writes of concurrent requests are serialized, but may interleave. w is not closed.
+func TeeResponse(w io.Writer) TripFunc {
+	if w == nil {
+		panic("trip: tee writer is nil")
+	}
+	lw := &lockedWriter{w: w}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := send(t, r)
+			if err != nil || resp.Body == nil {
+				return resp, err
+			}
+			resp.Body = &teeBody{Reader: io.TeeReader(resp.Body, lw), Closer: resp.Body}
+			return resp, err
+		})
+	}
+}
+
+// TeeRequest writes the body of every request to w while it is sent.
+// The body is streamed, not buffered. Bodies recreated through GetBody, e.g. by the
+// transport or by Retry, are not written to w again, so every body is written once
+// regardless of whether TeeRequest is placed before or after Retry. Writes of
+// concurrent requests are serialized, but may interleave. w is not closed.
+// Errors writing to w fail the request.
+func TeeRequest(w io.Writer) TripFunc {
+	return TeeRequestLimit(w, -1)
+}
+
+// TeeRequestLimit works like TeeRequest, but writes at most limit bytes of every
+// request body to w. The request body is sent in full either way.
+// A negative limit writes the whole body.
+func TeeRequestLimit(w io.Writer, limit int64) TripFunc {
+	if w == nil {
+		panic("trip: tee writer is nil")
+	}
+	lw := &lockedWriter{w: w}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if attempt, ok := AttemptFromContext(r.Context()); ok && attempt > 1 {
+				return t.RoundTrip(r)
+			}
+			if hasBody(r) {
+				var dst io.Writer = lw
+				if limit >= 0 {
+					dst = &limitedWriter{w: lw, n: limit}
+				}
+				r.Body = &teeBody{Reader: io.TeeReader(r.Body, dst), Closer: r.Body}
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+type teeBody struct {
+	io.Reader
+	io.Closer
+}
+
+// lockedWriter serializes writes to w.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// limitedWriter writes the first n bytes to w and discards the rest.
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.n <= 0 {
+		return len(p), nil
+	}
+	q := p
+	if int64(len(q)) > lw.n {
+		q = q[:lw.n]
+	}
+	n, err := lw.w.Write(q)
+	lw.n -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,304 @@
+package trip_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestVerifyContentLength(t *testing.T) {
+	tests := []struct {
+		body     string
+		length   int64
+		mismatch bool
+	}{
+		{body: "hello", length: 5},
+		{body: "hello", length: 10, mismatch: true},
+		{body: "hello", length: 3, mismatch: true},
+		{body: "hello", length: -1},
+	}
+
+	for _, tt := range tests {
+		resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 200, ContentLength: tt.length, Body: io.NopCloser(strings.NewReader(tt.body))}, nil
+		}, trip.VerifyContentLength())
+
+		_, err := io.ReadAll(resp.Body)
+		assertEqual(t, errors.Is(err, trip.ErrContentLengthMismatch), tt.mismatch)
+	}
+}
+
+// stallingBody returns its content with the first read and blocks further reads until
+// it is closed.
+type stallingBody struct {
+	content string
+	reads   int
+	stalled chan struct{}
+	closed  chan struct{}
+}
+
+func (b *stallingBody) Read(p []byte) (int, error) {
+	b.reads++
+	if b.reads == 1 {
+		return copy(p, b.content), nil
+	}
+	close(b.stalled)
+	<-b.closed
+	return 0, io.ErrClosedPipe
+}
+
+func (b *stallingBody) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestBodyReadTimeout(t *testing.T) {
+	clock := useManualClock(t)
+
+	stalling := &stallingBody{content: "hello", stalled: make(chan struct{}), closed: make(chan struct{})}
+	resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: stalling}, nil
+	}, trip.BodyReadTimeout(50*time.Millisecond))
+
+	type result struct {
+		body []byte
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		body, err := io.ReadAll(resp.Body)
+		done <- result{body, err}
+	}()
+
+	<-stalling.stalled
+	clock.Advance(50 * time.Millisecond)
+	res := <-done
+	assertEqual(t, string(res.body), "hello")
+	assertEqual(t, errors.Is(res.err, trip.ErrBodyReadTimeout), true)
+}
+
+func TestBodyReadTimeoutSteady(t *testing.T) {
+	resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("hello"))}, nil
+	}, trip.BodyReadTimeout(time.Second))
+
+	body, err := io.ReadAll(resp.Body)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(body), "hello")
+}
+
+func TestMarshalJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assertEqual(t, string(body), `{"name":"trip","stars":5}`)
+		assertEqual(t, r.Header.Get("Content-Type"), "application/json")
+		assertEqual(t, r.ContentLength, int64(len(body)))
+	}))
+	defer server.Close()
+
+	payload := struct {
+		Name  string `json:"name"`
+		Stars int    `json:"stars"`
+	}{"trip", 5}
+
+	client := &http.Client{Transport: trip.Default(trip.MarshalJSON())}
+	req, _ := http.NewRequestWithContext(trip.WithJSONBody(context.Background(), payload), "POST", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestMarshalJSONError(t *testing.T) {
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, nil
+	}), trip.MarshalJSON())
+
+	req := httptest.NewRequest("POST", "http://example.com/", nil)
+	req = req.WithContext(trip.WithJSONBody(req.Context(), make(chan int)))
+	_, err := transport.RoundTrip(req)
+
+	assertNotEqual(t, err, nil)
+	assertEqual(t, calls, 0)
+}
+
+func TestTeeResponse(t *testing.T) {
+	var audit bytes.Buffer
+	body := &countingBody{Reader: strings.NewReader("response body")}
+
+	resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: body}, nil
+	}, trip.TeeResponse(&audit))
+
+	read, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assertEqual(t, string(read), "response body")
+	assertEqual(t, audit.String(), "response body")
+	assertEqual(t, body.closed, true)
+}
+
+func TestTeeRequest(t *testing.T) {
+	var audit bytes.Buffer
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assertEqual(t, string(body), "request body")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: trip.Default(trip.TeeRequest(&audit))}
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("request body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	assertEqual(t, audit.String(), "request body")
+}
+
+func TestTeeRequestLimit(t *testing.T) {
+	var audit bytes.Buffer
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		assertEqual(t, string(body), "request body")
+
+		again, _ := r.GetBody()
+		body, _ = io.ReadAll(again)
+		assertEqual(t, string(body), "request body")
+		return nil, nil
+	}), trip.TeeRequestLimit(&audit, 7))
+
+	req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("request body"))
+	transport.RoundTrip(req)
+
+	assertEqual(t, audit.String(), "request")
+}
+
+func TestTeeRequestRetry(t *testing.T) {
+	useFakeClock(t)
+
+	for _, placement := range []string{"before", "after"} {
+		var audit bytes.Buffer
+		calls := 0
+		transport := trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			io.ReadAll(r.Body)
+			if calls < 3 {
+				return nil, errors.New("network error")
+			}
+			return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+		})
+		trips := []trip.TripFunc{trip.TeeRequest(&audit), trip.Retry(3, time.Second)}
+		if placement == "after" {
+			trips[0], trips[1] = trips[1], trips[0]
+		}
+
+		req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("request body"))
+		trip.New(transport, trips...).RoundTrip(req)
+
+		assertEqual(t, calls, 3)
+		assertEqual(t, audit.String(), "request body")
+	}
+}
+
+// onceReader is a request body that fails when it is read again after EOF.
+type onceReader struct {
+	r    io.Reader
+	done bool
+}
+
+func (o *onceReader) Read(p []byte) (int, error) {
+	if o.done {
+		return 0, errors.New("body read twice")
+	}
+	n, err := o.r.Read(p)
+	if err == io.EOF {
+		o.done = true
+	}
+	return n, err
+}
+
+func TestBufferBody(t *testing.T) {
+	var seen []string
+	reader := func(t http.RoundTripper) http.RoundTripper {
+		return trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			seen = append(seen, string(body))
+			r.Body, _ = r.GetBody()
+			return t.RoundTrip(r)
+		})
+	}
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		seen = append(seen, string(body))
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}), reader, trip.ContentDigest("sha-256"), reader, trip.BufferBody(1024))
+
+	req := httptest.NewRequest("POST", "http://example.com/", io.NopCloser(&onceReader{r: strings.NewReader("request body")}))
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, strings.Join(seen, ","), "request body,request body,request body")
+}
+
+func TestBufferBodyLimit(t *testing.T) {
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.GetBody == nil, true)
+		body, _ := io.ReadAll(r.Body)
+		assertEqual(t, string(body), "request body")
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}), trip.BufferBody(7))
+
+	req := httptest.NewRequest("POST", "http://example.com/", io.NopCloser(strings.NewReader("request body")))
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransferEncoding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%v %d %s", r.TransferEncoding, r.ContentLength, body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		chunked  bool
+		body     io.Reader
+		expected string
+	}{
+		{chunked: true, body: strings.NewReader("request body"), expected: "[chunked] -1 request body"},
+		{chunked: false, body: io.NopCloser(strings.NewReader("request body")), expected: "[] 12 request body"},
+	}
+
+	for _, tt := range tests {
+		client := &http.Client{Transport: trip.Default(trip.TransferEncoding(tt.chunked))}
+		resp, err := client.Post(server.URL, "text/plain", tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assertEqual(t, string(got), tt.expected)
+	}
+}
@@ -0,0 +1,122 @@
+package trip
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by CircuitBreakerPerHost for requests to a host whose
+// circuit is open.
+var ErrCircuitOpen = errors.New("trip: circuit breaker is open")
+
+// CircuitBreakerPerHost stops sending requests to a host after failureThreshold
+// consecutive failures, which are errors and responses with a 5xx status code. While
+// the circuit of a host is open, requests to it fail right away with ErrCircuitOpen.
+// After cooldown, a single trial request is let through: if it succeeds, the circuit
+// is closed again, otherwise it stays open for another cooldown. Results of requests
+// started before the circuit opened are ignored. Every host has its own circuit, so a
+// failing host doesn't affect requests to others.
+func CircuitBreakerPerHost(failureThreshold int, cooldown time.Duration) TripFunc {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		breakers = map[string]*breaker{}
+	)
+
+	get := func(host string) *breaker {
+		mu.Lock()
+		defer mu.Unlock()
+		b, ok := breakers[host]
+		if !ok {
+			b = &breaker{threshold: failureThreshold, cooldown: cooldown}
+			breakers[host] = b
+		}
+		return b
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			b := get(r.URL.Host)
+			ticket, ok := b.allow()
+			if !ok {
+				return nil, ErrCircuitOpen
+			}
+
+			resp, err := send(t, r)
+			b.record(ticket, err != nil || resp.StatusCode >= 500)
+			return resp, err
+		})
+	}
+}
+
+// breaker is the circuit of a single host.
+type breaker struct {
+	mu         sync.Mutex
+	threshold  int
+	cooldown   time.Duration
+	failures   int
+	openUntil  time.Time
+	trial      bool
+	generation uint64
+}
+
+// breakerTicket identifies a request allowed by a breaker. The generation of the
+// breaker changes whenever the circuit opens or closes, so that results of requests
+// started before can be told apart.
+type breakerTicket struct {
+	generation uint64
+	trial      bool
+}
+
+// allow reports whether a request may be sent. Once the circuit is open and the
+// cooldown has passed, only a single trial request is allowed until its result is
+// recorded.
+func (b *breaker) allow() (breakerTicket, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return breakerTicket{generation: b.generation}, true
+	}
+	if b.trial || clk.Now().Before(b.openUntil) {
+		return breakerTicket{}, false
+	}
+	b.trial = true
+	return breakerTicket{generation: b.generation, trial: true}, true
+}
+
+// record records the result of a request that was allowed. Results of requests
+// started before the circuit last opened or closed are ignored, so that only the
+// trial request decides whether an open circuit closes again.
+func (b *breaker) record(ticket breakerTicket, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ticket.generation != b.generation {
+		return
+	}
+	if ticket.trial {
+		b.trial = false
+		if failed {
+			b.openUntil = clk.Now().Add(b.cooldown)
+			return
+		}
+		b.failures = 0
+		b.generation++
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures == b.threshold {
+		b.openUntil = clk.Now().Add(b.cooldown)
+		b.generation++
+	}
+}
@@ -0,0 +1,104 @@
+package trip_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestCircuitBreakerPerHost(t *testing.T) {
+	clock := useFakeClock(t)
+
+	healthy := map[string]bool{"good.example.com": true}
+	calls := map[string]int{}
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls[r.URL.Host]++
+		if !healthy[r.URL.Host] {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}), trip.CircuitBreakerPerHost(3, time.Minute))
+
+	send := func(host string) error {
+		_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://"+host+"/", nil))
+		return err
+	}
+
+	for i := 0; i < 5; i++ {
+		send("bad.example.com")
+		assertEqual(t, send("good.example.com"), nil)
+	}
+	assertEqual(t, calls["bad.example.com"], 3)
+	assertEqual(t, calls["good.example.com"], 5)
+	assertEqual(t, send("bad.example.com"), trip.ErrCircuitOpen)
+
+	// After the cooldown, a failed trial request opens the circuit again.
+	clock.Advance(time.Minute)
+	assertEqual(t, send("bad.example.com").Error(), "connection refused")
+	assertEqual(t, send("bad.example.com"), trip.ErrCircuitOpen)
+	assertEqual(t, calls["bad.example.com"], 4)
+
+	// A successful trial request closes it.
+	clock.Advance(time.Minute)
+	healthy["bad.example.com"] = true
+	assertEqual(t, send("bad.example.com"), nil)
+	assertEqual(t, send("bad.example.com"), nil)
+	assertEqual(t, calls["bad.example.com"], 6)
+}
+
+func TestCircuitBreakerServerErrors(t *testing.T) {
+	useFakeClock(t)
+
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+	}), trip.CircuitBreakerPerHost(2, time.Minute))
+
+	for i := 0; i < 4; i++ {
+		transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	}
+	assertEqual(t, calls, 2)
+}
+
+func TestCircuitBreakerIgnoresStaleResults(t *testing.T) {
+	clock := useFakeClock(t)
+
+	slow := make(chan struct{})
+	started := make(chan struct{})
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/slow":
+			close(started)
+			<-slow
+			return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+		case "/ok":
+			return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+		}
+		return nil, errors.New("connection refused")
+	}), trip.CircuitBreakerPerHost(1, time.Minute))
+
+	send := func(path string) error {
+		_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com"+path, nil))
+		return err
+	}
+
+	done := make(chan error)
+	go func() { done <- send("/slow") }()
+	<-started
+
+	// The circuit opens while the slow request is in flight. Its success doesn't
+	// close the circuit again.
+	send("/fail")
+	close(slow)
+	assertEqual(t, <-done, nil)
+	assertEqual(t, send("/ok"), trip.ErrCircuitOpen)
+
+	clock.Advance(time.Minute)
+	assertEqual(t, send("/ok"), nil)
+	assertEqual(t, send("/ok"), nil)
+}
@@ -0,0 +1,82 @@
+package trip
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// retryBudgetWindow is the number of seconds over which RetryBudget tracks requests
+// and retries.
+const retryBudgetWindow = 10
+
+type retryBudgetKey struct{}
+
+// RetryBudget limits the retries of all requests sent through it to ratio times the
+// number of requests, plus minPerSecond retries per second. Requests and retries are
+// counted over a sliding window of 10 seconds. Once the budget is exhausted, Retry
+// returns the result of the current attempt instead of retrying, which prevents retry
+// storms from amplifying the load on a failing server.
+//
+// RetryBudget must be placed after Retry in the list of trip functions.
+func RetryBudget(ratio float64, minPerSecond int) TripFunc {
+	b := &retryBudget{ratio: ratio, minPerSecond: minPerSecond}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			b.request()
+			return t.RoundTrip(r.WithContext(context.WithValue(r.Context(), retryBudgetKey{}, b)))
+		})
+	}
+}
+
+type retryBudget struct {
+	ratio        float64
+	minPerSecond int
+
+	mu      sync.Mutex
+	buckets [retryBudgetWindow]retryBudgetBucket
+}
+
+// retryBudgetBucket counts the requests and retries of a single second.
+type retryBudgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+// bucket returns the bucket of the current second. b.mu must be held.
+func (b *retryBudget) bucket() *retryBudgetBucket {
+	now := clk.Now().Unix()
+	bucket := &b.buckets[now%retryBudgetWindow]
+	if bucket.second != now {
+		*bucket = retryBudgetBucket{second: now}
+	}
+	return bucket
+}
+
+func (b *retryBudget) request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bucket().requests++
+}
+
+// allowRetry reports whether the budget allows another retry and records it if so.
+func (b *retryBudget) allowRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.bucket()
+	var requests, retries int
+	for _, bucket := range b.buckets {
+		if current.second-bucket.second < retryBudgetWindow {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	if float64(retries) >= b.ratio*float64(requests)+float64(b.minPerSecond*retryBudgetWindow) {
+		return false
+	}
+	current.retries++
+	return true
+}
@@ -0,0 +1,57 @@
+package trip_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestRetryBudget(t *testing.T) {
+	useFakeClock(t)
+
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.Retry(3, 0, trip.RetryableStatusCodes...), trip.RetryBudget(0.1, 0))
+
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		resp, err := transport.RoundTrip(req)
+		assertEqual(t, err, nil)
+		assertEqual(t, resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	// 100 requests allow at most 10 retries, instead of 200 without a budget.
+	assertEqual(t, calls, 100+10)
+}
+
+func TestRetryBudgetMinPerSecond(t *testing.T) {
+	clock := useFakeClock(t)
+
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.Retry(2, 0, trip.RetryableStatusCodes...), trip.RetryBudget(0, 1))
+
+	send := func() {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		transport.RoundTrip(req)
+	}
+
+	for i := 0; i < 20; i++ {
+		send()
+	}
+	assertEqual(t, calls, 20+10)
+
+	// Retries leave the window after 10 seconds and free the budget again.
+	clock.Advance(11 * time.Second)
+	calls = 0
+	send()
+	assertEqual(t, calls, 2)
+}
@@ -0,0 +1,109 @@
+package trip
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	charsetsMu sync.RWMutex
+	charsets   = map[string]func(io.Reader) io.Reader{
+		"iso-8859-1": newLatin1Reader,
+		"latin1":     newLatin1Reader,
+	}
+)
+
+// RegisterCharset makes a decoder from a charset to UTF-8 available to Transcode.
+// Only a decoder for ISO-8859-1 is registered by default. As trip has no dependencies,
+// decoders for other charsets, like windows-1252 or Shift_JIS, have to be registered
+// from the package of choice:
+//
+//	trip.RegisterCharset("windows-1252", func(r io.Reader) io.Reader {
+//		return charmap.Windows1252.NewDecoder().Reader(r) // golang.org/x/text/encoding/charmap
+//	})
+func RegisterCharset(charset string, decoder func(io.Reader) io.Reader) {
+	charsetsMu.Lock()
+	defer charsetsMu.Unlock()
+	charsets[strings.ToLower(charset)] = decoder
+}
+
+func charsetDecoder(charset string) func(io.Reader) io.Reader {
+	charsetsMu.RLock()
+	defer charsetsMu.RUnlock()
+	return charsets[strings.ToLower(charset)]
+}
+
+// Transcode converts response bodies to UTF-8 if their `Content-Type` header declares
+// another charset with a registered decoder, and sets the charset in the header to
+// utf-8. Only ISO-8859-1 is converted out of the box. Responses without a charset, or
+// with one that has no decoder registered with RegisterCharset, are passed through
+// untouched.
+func Transcode() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := send(t, r)
+			if err != nil || resp.Body == nil {
+				return resp, err
+			}
+
+			mediaType, params, perr := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			if perr != nil {
+				return resp, err
+			}
+			charset := strings.ToLower(params["charset"])
+			if charset == "" || charset == "utf-8" {
+				return resp, err
+			}
+			decode := charsetDecoder(charset)
+			if decode == nil {
+				return resp, err
+			}
+
+			resp.Body = &teeBody{Reader: decode(resp.Body), Closer: resp.Body}
+			params["charset"] = "utf-8"
+			resp.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			return resp, err
+		})
+	}
+}
+
+// latin1Reader decodes ISO-8859-1, where every byte is the code point of a rune.
+type latin1Reader struct {
+	r       io.Reader
+	raw     []byte
+	pending []byte
+	err     error
+}
+
+func newLatin1Reader(r io.Reader) io.Reader {
+	return &latin1Reader{r: r}
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for len(l.pending) == 0 {
+		if l.err != nil {
+			return 0, l.err
+		}
+		if len(l.raw) < len(p) {
+			l.raw = make([]byte, len(p))
+		}
+		var n int
+		n, l.err = l.r.Read(l.raw[:len(p)])
+		l.pending = l.pending[:0]
+		for _, b := range l.raw[:n] {
+			l.pending = utf8.AppendRune(l.pending, rune(b))
+		}
+	}
+	n := copy(p, l.pending)
+	l.pending = l.pending[n:]
+	return n, nil
+}
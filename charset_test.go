@@ -0,0 +1,66 @@
+package trip_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/philippta/trip"
+)
+
+func TestTranscodeLatin1(t *testing.T) {
+	latin1 := []byte{'C', 'a', 'f', 0xe9, ' ', 'M', 0xfc, 'n', 'c', 'h', 'e', 'n'}
+
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		header := http.Header{"Content-Type": {"text/plain; charset=ISO-8859-1"}}
+		return &http.Response{StatusCode: 200, Header: header, Body: io.NopCloser(bytes.NewReader(latin1))}, nil
+	}, trip.Transcode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	assertEqual(t, string(body), "Café München")
+	assertEqual(t, resp.Header.Get("Content-Type"), "text/plain; charset=utf-8")
+}
+
+func TestTranscodeSmallReads(t *testing.T) {
+	resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+		header := http.Header{"Content-Type": {"text/plain; charset=latin1"}}
+		return &http.Response{StatusCode: 200, Header: header, Body: io.NopCloser(bytes.NewReader([]byte{0xe4, 0xf6, 0xfc}))}, nil
+	}, trip.Transcode())
+
+	body, err := io.ReadAll(iotest.OneByteReader(resp.Body))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(body), "äöü")
+}
+
+func TestTranscodeRegistered(t *testing.T) {
+	trip.RegisterCharset("x-upper", func(r io.Reader) io.Reader {
+		raw, _ := io.ReadAll(r)
+		return strings.NewReader(strings.ToLower(string(raw)))
+	})
+	t.Cleanup(func() { trip.UnregisterCharset("x-upper") })
+
+	tests := []struct {
+		contentType string
+		expected    string
+	}{
+		{contentType: "text/plain; charset=x-upper", expected: "hello"},
+		{contentType: "text/plain; charset=unknown", expected: "HELLO"},
+		{contentType: "text/plain", expected: "HELLO"},
+	}
+
+	for _, tt := range tests {
+		resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+			header := http.Header{"Content-Type": {tt.contentType}}
+			return &http.Response{StatusCode: 200, Header: header, Body: io.NopCloser(strings.NewReader("HELLO"))}, nil
+		}, trip.Transcode())
+
+		body, _ := io.ReadAll(resp.Body)
+		assertEqual(t, string(body), tt.expected)
+	}
+}
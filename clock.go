@@ -0,0 +1,45 @@
+package trip
+
+import (
+	"context"
+	"time"
+)
+
+// clock provides the current time and timers to time based trips.
+// It is swapped out in tests to avoid waiting on real time.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+
+	// AfterFunc calls f in its own goroutine after d, unless stop is called first.
+	// Stop reports whether it prevented the call, like time.Timer.Stop.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+var clk clock = realClock{}
+
+func since(t time.Time) time.Duration {
+	return clk.Now().Sub(t)
+}
+
+// sleep waits for d to pass or ctx to be done, whichever happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 || ctx.Err() != nil {
+		return ctx.Err()
+	}
+	select {
+	case <-clk.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,215 @@
+package trip_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+// fakeClock is a clock that advances instantly when waited on. Functions scheduled
+// with AfterFunc are called when it is advanced past their time.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+	timers timers
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	c := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	t.Cleanup(trip.SetClock(c))
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timers.add(&c.mu, c.now.Add(d), f)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	due := c.timers.due(c.now)
+	c.mu.Unlock()
+	for _, f := range due {
+		f()
+	}
+}
+
+func (c *fakeClock) Sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.sleeps...)
+}
+
+// manualClock is a clock whose timers only fire when it is advanced, to observe
+// requests while they are waiting.
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []manualTimer
+	timers  timers
+}
+
+type manualTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func useManualClock(t *testing.T) *manualClock {
+	c := &manualClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	t.Cleanup(trip.SetClock(c))
+	return c
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, manualTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *manualClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timers.add(&c.mu, c.now.Add(d), f)
+}
+
+// Advance moves the clock forward and fires all timers that are due.
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiting
+	due := c.timers.due(c.now)
+	c.mu.Unlock()
+	for _, f := range due {
+		f()
+	}
+}
+
+// WaitForTimers blocks until n timers are pending.
+func (c *manualClock) WaitForTimers(n int) {
+	for {
+		c.mu.Lock()
+		pending := len(c.waiters) + len(c.timers)
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// timers are the functions scheduled with AfterFunc on a test clock, guarded by the
+// mutex of the clock.
+type timers map[*funcTimer]bool
+
+type funcTimer struct {
+	at time.Time
+	f  func()
+}
+
+// add schedules f at the given time and returns a function to stop it.
+func (ts *timers) add(mu *sync.Mutex, at time.Time, f func()) func() bool {
+	if *ts == nil {
+		*ts = timers{}
+	}
+	timer := &funcTimer{at: at, f: f}
+	(*ts)[timer] = true
+	return func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		pending := (*ts)[timer]
+		delete(*ts, timer)
+		return pending
+	}
+}
+
+// due removes and returns the functions scheduled at or before now.
+func (ts timers) due(now time.Time) []func() {
+	var due []func()
+	for timer := range ts {
+		if !timer.at.After(now) {
+			due = append(due, timer.f)
+			delete(ts, timer)
+		}
+	}
+	return due
+}
+
+func TestRetryFakeClock(t *testing.T) {
+	var (
+		clock    = useFakeClock(t)
+		calls    []time.Time
+		attempts = 4
+		backoff  = trip.ExponentialBackoff(time.Second, time.Minute)
+	)
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		calls = append(calls, clock.Now())
+		return nil, errors.New("network error")
+	}, trip.RetryWith(attempts, backoff))
+
+	assertEqual(t, len(calls), attempts)
+	assertEqual(t, calls[1].Sub(calls[0]), time.Second)
+	assertEqual(t, calls[2].Sub(calls[1]), 2*time.Second)
+	assertEqual(t, calls[3].Sub(calls[2]), 4*time.Second)
+	assertEqual(t, len(clock.Sleeps()), attempts-1)
+}
+
+func TestLoggerFakeClock(t *testing.T) {
+	var (
+		clock = useFakeClock(t)
+		msg   string
+	)
+
+	logf := func(format string, v ...any) {
+		msg = fmt.Sprintf(format, v...)
+	}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		clock.Advance(12340 * time.Microsecond)
+		return &http.Response{Status: "200 OK", StatusCode: 200, Body: io.NopCloser(strings.NewReader("foo"))}, nil
+	}, trip.Logger(logf))
+
+	assertEqual(t, msg, "POST http://example.com/foo?bar=yes - 200 OK - 12.34ms")
+}
@@ -0,0 +1,95 @@
+package trip
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CoalesceWindow shares a single round trip between GET and HEAD requests to the same
+// URL that arrive within window of each other. A request that starts a round trip is
+// sent right away; requests to the same URL arriving within window after it, whether
+// the round trip is still in flight or already completed, are answered with a copy of
+// its response instead of being sent. Response bodies are buffered in memory to be
+// shared. Requests with other methods or with a body are sent as usual.
+//
+// Only requests with the same `Authorization` and `Cookie` headers share a round trip,
+// so that responses aren't handed to callers with other credentials. Other headers
+// aren't compared. If the request sending the round trip is canceled, requests waiting
+// for it are sent on their own instead.
+func CoalesceWindow(window time.Duration) TripFunc {
+	var (
+		mu    sync.Mutex
+		calls = map[string]*coalescedCall{}
+	)
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead || hasBody(r) {
+				return t.RoundTrip(r)
+			}
+			key := r.Method + " " + r.URL.String() + "\n" + r.Header.Get("Authorization") + "\n" + strings.Join(r.Header.Values("Cookie"), "; ")
+
+			mu.Lock()
+			c, ok := calls[key]
+			if ok && since(c.start) < window {
+				mu.Unlock()
+				select {
+				case <-c.done:
+					if c.canceled && r.Context().Err() == nil {
+						return t.RoundTrip(r)
+					}
+					return c.response(r)
+				case <-r.Context().Done():
+					return nil, r.Context().Err()
+				}
+			}
+			for k, c := range calls {
+				if since(c.start) >= window {
+					delete(calls, k)
+				}
+			}
+			c = &coalescedCall{start: clk.Now(), done: make(chan struct{})}
+			calls[key] = c
+			mu.Unlock()
+
+			c.resp, c.err = send(t, r)
+			c.canceled = c.err != nil && r.Context().Err() != nil
+			if c.err == nil && c.resp.Body != nil {
+				c.body, c.err = io.ReadAll(c.resp.Body)
+				c.resp.Body.Close()
+			}
+			close(c.done)
+
+			return c.response(r)
+		})
+	}
+}
+
+type coalescedCall struct {
+	start time.Time
+	done  chan struct{}
+
+	resp *http.Response
+	body []byte
+	err  error
+
+	// canceled reports whether the round trip failed because the context of the
+	// request sending it is done.
+	canceled bool
+}
+
+// response returns a copy of the response of c for r.
+func (c *coalescedCall) response(r *http.Request) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	resp := *c.resp
+	resp.Header = c.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(c.body))
+	resp.Request = r
+	return &resp, nil
+}
@@ -0,0 +1,158 @@
+package trip_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestCoalesceWindow(t *testing.T) {
+	clock := useFakeClock(t)
+
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("shared"))}, nil
+	}), trip.CoalesceWindow(5*time.Millisecond))
+
+	get := func(url string) string {
+		resp, err := transport.RoundTrip(httptest.NewRequest("GET", url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	assertEqual(t, get("http://example.com/a"), "shared")
+	clock.Advance(2 * time.Millisecond)
+	assertEqual(t, get("http://example.com/a"), "shared")
+	clock.Advance(2 * time.Millisecond)
+	assertEqual(t, get("http://example.com/a"), "shared")
+	assertEqual(t, calls, 1)
+
+	get("http://example.com/b")
+	assertEqual(t, calls, 2)
+
+	clock.Advance(2 * time.Millisecond)
+	get("http://example.com/a")
+	assertEqual(t, calls, 3)
+}
+
+func TestCoalesceWindowInFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		<-release
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("shared"))}, nil
+	}), trip.CoalesceWindow(time.Minute))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			bodies[i] = string(body)
+		}(i)
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	assertEqual(t, calls.Load(), int32(1))
+	for _, body := range bodies {
+		assertEqual(t, body, "shared")
+	}
+}
+
+func TestCoalesceWindowPost(t *testing.T) {
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}), trip.CoalesceWindow(time.Minute))
+
+	transport.RoundTrip(httptest.NewRequest("POST", "http://example.com/", nil))
+	transport.RoundTrip(httptest.NewRequest("POST", "http://example.com/", nil))
+	assertEqual(t, calls, 2)
+}
+
+func TestCoalesceWindowLeaderCanceled(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{}, 2)
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		started <- struct{}{}
+		if r.Header.Get("X-Leader") != "" {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("own"))}, nil
+	}), trip.CoalesceWindow(time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := httptest.NewRequest("GET", "http://example.com/", nil).WithContext(ctx)
+	leader.Header.Set("X-Leader", "1")
+	leaderErr := make(chan error)
+	go func() {
+		_, err := transport.RoundTrip(leader)
+		leaderErr <- err
+	}()
+	<-started
+
+	follower := make(chan string)
+	go func() {
+		resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+		if err != nil {
+			t.Error(err)
+			follower <- ""
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		follower <- string(body)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	cancel()
+	assertEqual(t, <-leaderErr, context.Canceled)
+	assertEqual(t, <-follower, "own")
+	assertEqual(t, calls.Load(), int32(2))
+}
+
+func TestCoalesceWindowCredentials(t *testing.T) {
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(r.Header.Get("Authorization") + r.Header.Get("Cookie")))}, nil
+	}), trip.CoalesceWindow(time.Minute))
+
+	get := func(header, value string) string {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set(header, value)
+		resp, _ := transport.RoundTrip(req)
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	assertEqual(t, get("Authorization", "Bearer alice"), "Bearer alice")
+	assertEqual(t, get("Authorization", "Bearer bob"), "Bearer bob")
+	assertEqual(t, get("Authorization", "Bearer alice"), "Bearer alice")
+	assertEqual(t, get("Cookie", "session=carol"), "session=carol")
+	assertEqual(t, calls, 3)
+}
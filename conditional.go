@@ -0,0 +1,66 @@
+package trip
+
+import (
+	"net/http"
+	"sync"
+)
+
+// ETagStore stores the ETags of previously fetched URLs.
+type ETagStore interface {
+	Get(url string) (etag string, ok bool)
+	Set(url, etag string)
+}
+
+// NewETagStore creates a new in-memory ETagStore that is safe for concurrent use.
+func NewETagStore() ETagStore {
+	return &memoryETagStore{etags: map[string]string{}}
+}
+
+type memoryETagStore struct {
+	mu    sync.RWMutex
+	etags map[string]string
+}
+
+func (s *memoryETagStore) Get(url string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	etag, ok := s.etags[url]
+	return etag, ok
+}
+
+func (s *memoryETagStore) Set(url, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.etags[url] = etag
+}
+
+// ConditionalGet sets the `If-None-Match` header on GET requests to the ETag stored
+// for the URL and stores the ETag of every successful response. Responses with
+// status 304 Not Modified are returned as-is for the caller to handle.
+func ConditionalGet(store ETagStore) TripFunc {
+	if store == nil {
+		panic("trip: etag store is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method != http.MethodGet {
+				return t.RoundTrip(r)
+			}
+
+			url := r.URL.String()
+			if etag, ok := store.Get(url); ok && r.Header.Get("If-None-Match") == "" {
+				r.Header.Set("If-None-Match", etag)
+			}
+
+			resp, err := send(t, r)
+			if err != nil {
+				return resp, err
+			}
+
+			if etag := resp.Header.Get("ETag"); resp.StatusCode == http.StatusOK && etag != "" {
+				store.Set(url, etag)
+			}
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package trip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestConditionalGet(t *testing.T) {
+	var conditions []string
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			return &http.Response{StatusCode: http.StatusNotModified, Header: http.Header{}, Body: http.NoBody}, nil
+		}
+		header := http.Header{}
+		header.Set("ETag", `"v1"`)
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody}, nil
+	}), trip.ConditionalGet(trip.NewETagStore()))
+
+	first, _ := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/foo", nil))
+	second, _ := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/foo", nil))
+	other, _ := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/bar", nil))
+
+	assertEqual(t, len(conditions), 3)
+	assertEqual(t, conditions[0], "")
+	assertEqual(t, conditions[1], `"v1"`)
+	assertEqual(t, conditions[2], "")
+	assertEqual(t, first.StatusCode, http.StatusOK)
+	assertEqual(t, second.StatusCode, http.StatusNotModified)
+	assertEqual(t, other.StatusCode, http.StatusOK)
+}
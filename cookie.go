@@ -0,0 +1,31 @@
+package trip
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+)
+
+// CookieJar adds cookies from jar to every request and stores cookies received
+// with the response back in jar. If jar is nil, a new in-memory jar is used.
+func CookieJar(jar http.CookieJar) TripFunc {
+	if jar == nil {
+		jar, _ = cookiejar.New(nil)
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			for _, cookie := range jar.Cookies(r.URL) {
+				r.AddCookie(cookie)
+			}
+
+			resp, err := send(t, r)
+			if err != nil {
+				return resp, err
+			}
+
+			if cookies := resp.Cookies(); len(cookies) > 0 {
+				jar.SetCookies(r.URL, cookies)
+			}
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package trip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestCookieJar(t *testing.T) {
+	var cookies []string
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		cookies = append(cookies, r.Header.Get("Cookie"))
+
+		header := http.Header{}
+		header.Add("Set-Cookie", "session=abc123; Path=/")
+		return &http.Response{StatusCode: 200, Header: header, Request: r}, nil
+	}), trip.CookieJar(nil))
+
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/login", nil))
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/profile", nil))
+	transport.RoundTrip(httptest.NewRequest("GET", "http://other.example.com/", nil))
+
+	assertEqual(t, len(cookies), 3)
+	assertEqual(t, cookies[0], "")
+	assertEqual(t, cookies[1], "session=abc123")
+	assertEqual(t, cookies[2], "")
+}
@@ -0,0 +1,119 @@
+package trip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrSendTimeout is returned by SendTimeout when the response headers didn't arrive in
+// time after the request was written.
+var ErrSendTimeout = errors.New("trip: response headers not received in time")
+
+// DeadlineHeader sets the given header on every request with a context deadline to the
+// remaining time until the deadline, using the gRPC timeout format `{value}{unit}`
+// (e.g. `500m` for 500 milliseconds), as used by the `grpc-timeout` header.
+// Requests without deadline are left untouched.
+func DeadlineHeader(header string) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if deadline, ok := r.Context().Deadline(); ok {
+				r.Header.Set(header, grpcTimeout(deadline.Sub(clk.Now())))
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// SendTimeout cancels requests whose response headers don't arrive within d after the
+// request has been written to the connection, failing them with ErrSendTimeout. Unlike
+// a timeout on the whole request, time spent waiting for a connection from the pool,
+// dialing or sending the body doesn't count towards d. Reading the response body isn't
+// limited.
+func SendTimeout(d time.Duration) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			ctx, cancel := context.WithCancel(r.Context())
+
+			var (
+				mu       sync.Mutex
+				stop     func() bool
+				done     bool
+				timedOut bool
+			)
+			trace := &httptrace.ClientTrace{
+				WroteRequest: func(httptrace.WroteRequestInfo) {
+					mu.Lock()
+					defer mu.Unlock()
+					if done || stop != nil {
+						return
+					}
+					stop = clk.AfterFunc(d, func() {
+						mu.Lock()
+						defer mu.Unlock()
+						if !done {
+							timedOut = true
+							cancel()
+						}
+					})
+				},
+			}
+
+			resp, err := send(t, r.WithContext(httptrace.WithClientTrace(ctx, trace)))
+
+			mu.Lock()
+			done = true
+			if stop != nil {
+				stop()
+			}
+			expired := timedOut
+			mu.Unlock()
+
+			if err != nil {
+				cancel()
+				if expired {
+					return nil, ErrSendTimeout
+				}
+				return nil, err
+			}
+			if resp.Body == nil {
+				cancel()
+				return resp, nil
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		})
+	}
+}
+
+// grpcTimeout formats d as a gRPC timeout with at most 8 digits, using the finest
+// unit from milliseconds up to hours it fits into.
+func grpcTimeout(d time.Duration) string {
+	const maxValue = 99999999
+
+	if d < time.Millisecond {
+		if d < 0 {
+			d = 0
+		}
+		return strconv.FormatInt(d.Microseconds(), 10) + "u"
+	}
+
+	units := []struct {
+		d    time.Duration
+		unit string
+	}{
+		{time.Millisecond, "m"},
+		{time.Second, "S"},
+		{time.Minute, "M"},
+	}
+	for _, u := range units {
+		if v := d / u.d; v <= maxValue {
+			return strconv.FormatInt(int64(v), 10) + u.unit
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Hour), 10) + "H"
+}
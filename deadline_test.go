@@ -0,0 +1,81 @@
+package trip_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestDeadlineHeader(t *testing.T) {
+	clock := useFakeClock(t)
+
+	tests := []struct {
+		timeout  time.Duration
+		expected string
+	}{
+		{timeout: 500 * time.Millisecond, expected: "500m"},
+		{timeout: 90 * time.Second, expected: "90000m"},
+		{timeout: 48 * time.Hour, expected: "172800S"},
+		{timeout: 300 * time.Microsecond, expected: "300u"},
+		{timeout: -time.Second, expected: "0u"},
+	}
+
+	for _, tt := range tests {
+		ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(tt.timeout))
+		req := httptest.NewRequest("GET", "http://example.com/", nil).WithContext(ctx)
+
+		trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			assertEqual(t, r.Header.Get("Grpc-Timeout"), tt.expected)
+			return nil, nil
+		}), trip.DeadlineHeader("grpc-timeout")).RoundTrip(req)
+		cancel()
+	}
+}
+
+func TestDeadlineHeaderWithoutDeadline(t *testing.T) {
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		_, ok := r.Header["Grpc-Timeout"]
+		assertEqual(t, ok, false)
+		return nil, nil
+	}, trip.DeadlineHeader("grpc-timeout"))
+}
+
+func TestSendTimeout(t *testing.T) {
+	clock := useManualClock(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &http.Client{Transport: trip.Default(trip.SendTimeout(50 * time.Millisecond))}
+
+	resp, err := client.Get(server.URL + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assertEqual(t, resp.StatusCode, 200)
+
+	done := make(chan error)
+	go func() {
+		_, err := client.Get(server.URL + "/slow")
+		done <- err
+	}()
+	clock.WaitForTimers(1)
+	clock.Advance(50 * time.Millisecond)
+	assertEqual(t, errors.Is(<-done, trip.ErrSendTimeout), true)
+}
@@ -0,0 +1,52 @@
+package trip
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/http"
+)
+
+// ContentDigest sets the `Content-Digest` header of every request with a body to the
+// digest of the body as defined by RFC 9530, e.g. `sha-256=:RK/0qy18MlBSVnWgj...=:`.
+// algo is either sha-256 or sha-512. Requests without a body are sent unchanged. The
+// body is buffered in memory and restored.
+func ContentDigest(algo string) TripFunc {
+	if _, err := contentDigest(algo, nil); err != nil {
+		panic(err.Error())
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if !hasBody(r) {
+				return t.RoundTrip(r)
+			}
+
+			body, err := bufferedBytes(r)
+			if err != nil {
+				return nil, err
+			}
+
+			digest, _ := contentDigest(algo, body)
+			r.Header.Set("Content-Digest", digest)
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// contentDigest returns the value of a `Content-Digest` header as defined by RFC 9530
+// for body, using the algorithm sha-256 or sha-512.
+func contentDigest(algo string, body []byte) (string, error) {
+	var h hash.Hash
+	switch algo {
+	case "sha-256":
+		h = sha256.New()
+	case "sha-512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("trip: unsupported digest algorithm %q", algo)
+	}
+	h.Write(body)
+	return algo + "=:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + ":", nil
+}
@@ -0,0 +1,46 @@
+package trip_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestContentDigest(t *testing.T) {
+	tests := []struct {
+		algo     string
+		expected string
+	}{
+		{algo: "sha-256", expected: "sha-256=:RK/0qy18MlBSVnWgjwz6lZEWjP/lF5HF9bvEF8FabDg=:"},
+		{algo: "sha-512", expected: "sha-512=:YMAam51Jz/jOATT6/zvHrLVgOYTGFy1d6GJiOHTohq4yP+pgk4vf2aCsyRZOtw8MjkM7iw7yZ/WkppmM44T3qg==:"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "http://example.com/", strings.NewReader("{\"hello\": \"world\"}\n"))
+
+		trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			assertEqual(t, r.Header.Get("Content-Digest"), tt.expected)
+			body, _ := io.ReadAll(r.Body)
+			assertEqual(t, string(body), "{\"hello\": \"world\"}\n")
+			return nil, nil
+		}), trip.ContentDigest(tt.algo)).RoundTrip(req)
+	}
+}
+
+func TestContentDigestWithoutBody(t *testing.T) {
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Content-Digest"), "")
+		return nil, nil
+	}, trip.ContentDigest("sha-256"))
+}
+
+func TestContentDigestUnsupported(t *testing.T) {
+	defer func() {
+		assertEqual(t, recover(), any(`trip: unsupported digest algorithm "md5"`))
+	}()
+	trip.ContentDigest("md5")
+}
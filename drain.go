@@ -0,0 +1,34 @@
+package trip
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+)
+
+// ErrDraining is returned for requests started after Drainer.BeginDrain was called.
+var ErrDraining = errors.New("trip: transport is draining")
+
+// Drainer stops requests from being sent for a graceful shutdown. The zero value is
+// ready to use.
+type Drainer struct {
+	draining atomic.Bool
+}
+
+// Drain rejects every request with ErrDraining once BeginDrain was called. Requests
+// sent before are not affected and complete as usual.
+func (d *Drainer) Drain() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if d.draining.Load() {
+				return nil, ErrDraining
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// BeginDrain makes all trip functions created by Drain reject new requests.
+func (d *Drainer) BeginDrain() {
+	d.draining.Store(true)
+}
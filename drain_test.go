@@ -0,0 +1,39 @@
+package trip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestDrain(t *testing.T) {
+	var (
+		drainer  trip.Drainer
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		inFlight = make(chan error)
+	)
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}), drainer.Drain())
+
+	go func() {
+		_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/slow", nil))
+		inFlight <- err
+	}()
+	<-started
+
+	drainer.BeginDrain()
+	_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	assertEqual(t, err, trip.ErrDraining)
+
+	close(release)
+	assertEqual(t, <-inFlight, nil)
+}
@@ -0,0 +1,103 @@
+package trip
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]func(io.Reader) (io.ReadCloser, error){
+		"gzip": func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		"deflate": func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+	}
+)
+
+// RegisterDecoder makes a decoder for a content encoding available to AcceptEncoding.
+// Decoders for gzip and deflate are registered by default. As trip has no
+// dependencies, it can't decode zstd or br by itself; decoders for them have to be
+// registered from the package of choice:
+//
+//	trip.RegisterDecoder("zstd", func(r io.Reader) (io.ReadCloser, error) {
+//		d, err := zstd.NewReader(r) // github.com/klauspost/compress/zstd
+//		if err != nil {
+//			return nil, err
+//		}
+//		return d.IOReadCloser(), nil
+//	})
+func RegisterDecoder(encoding string, decoder func(io.Reader) (io.ReadCloser, error)) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(encoding)] = decoder
+}
+
+func decoder(encoding string) func(io.Reader) (io.ReadCloser, error) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	return decoders[strings.ToLower(encoding)]
+}
+
+// AcceptEncoding sets the `Accept-Encoding` header on every request to the given
+// content encodings and transparently decodes responses using one of them.
+// Only gzip and deflate are decoded out of the box. Responses with an encoding that
+// has no registered decoder, like zstd without a call to RegisterDecoder, are passed
+// through untouched, so only encodings with a decoder should be accepted.
+func AcceptEncoding(encodings ...string) TripFunc {
+	accepted := map[string]bool{}
+	for _, encoding := range encodings {
+		accepted[strings.ToLower(encoding)] = true
+	}
+	header := strings.Join(encodings, ", ")
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.Header.Set("Accept-Encoding", header)
+
+			resp, err := send(t, r)
+			if err != nil || resp.Body == nil {
+				return resp, err
+			}
+
+			encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+			if !accepted[encoding] {
+				return resp, err
+			}
+			decode := decoder(encoding)
+			if decode == nil {
+				return resp, err
+			}
+
+			body, err := decode(resp.Body)
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+
+			resp.Body = &decodedBody{ReadCloser: body, raw: resp.Body}
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			resp.Uncompressed = true
+			return resp, nil
+		})
+	}
+}
+
+// decodedBody closes both the decoder and the underlying raw body.
+type decodedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.raw.Close()
+}
@@ -0,0 +1,67 @@
+package trip_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestAcceptEncodingGzip(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("hello gzip"))
+	w.Close()
+
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Accept-Encoding"), "gzip, zstd")
+		return encodedResponse("gzip", buf.Bytes()), nil
+	}, trip.AcceptEncoding("gzip", "zstd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	assertEqual(t, string(body), "hello gzip")
+	assertEqual(t, resp.Header.Get("Content-Encoding"), "")
+	assertEqual(t, resp.Uncompressed, true)
+}
+
+func TestAcceptEncodingRegistered(t *testing.T) {
+	// Stand-in for a zstd decoder, as the real one lives outside the standard library.
+	trip.RegisterDecoder("zstd", func(r io.Reader) (io.ReadCloser, error) {
+		raw, err := io.ReadAll(r)
+		return io.NopCloser(strings.NewReader(strings.TrimPrefix(string(raw), "zstd:"))), err
+	})
+	t.Cleanup(func() { trip.UnregisterDecoder("zstd") })
+
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return encodedResponse("zstd", []byte("zstd:hello zstd")), nil
+	}, trip.AcceptEncoding("gzip", "zstd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	assertEqual(t, string(body), "hello zstd")
+}
+
+func TestAcceptEncodingUnknown(t *testing.T) {
+	resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return encodedResponse("br", []byte("raw")), nil
+	}, trip.AcceptEncoding("gzip", "br"))
+
+	body, _ := io.ReadAll(resp.Body)
+	assertEqual(t, string(body), "raw")
+	assertEqual(t, resp.Header.Get("Content-Encoding"), "br")
+}
+
+func encodedResponse(encoding string, body []byte) *http.Response {
+	header := http.Header{}
+	header.Set("Content-Encoding", encoding)
+	return &http.Response{StatusCode: 200, Header: header, Body: io.NopCloser(bytes.NewReader(body))}
+}
@@ -0,0 +1,115 @@
+package trip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxErrorBody is the maximum number of bytes of a response body kept in an HTTPError.
+const maxErrorBody = 4 << 10
+
+// HTTPError is returned by StatusError and JSONError for responses considered
+// unsuccessful.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+
+	// Err is the error returned by the extract function of JSONError, if any.
+	Err error
+}
+
+// Error satisfies the error interface.
+func (e *HTTPError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+	}
+	if e.Err != nil {
+		return "trip: unexpected status " + status + ": " + e.Err.Error()
+	}
+	return "trip: unexpected status " + status
+}
+
+// Unwrap returns the error returned by the extract function of JSONError.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError converts responses into an *HTTPError if isError reports true for their
+// status code. Up to 4 KiB of the response body are captured in the error before the
+// response body is closed.
+func StatusError(isError func(statusCode int) bool) TripFunc {
+	if isError == nil {
+		panic("trip: status error function is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := send(t, r)
+			if err != nil || !isError(resp.StatusCode) {
+				return resp, err
+			}
+
+			httpErr := &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header}
+			if resp.Body != nil {
+				httpErr.Body, _ = io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+				resp.Body.Close()
+			}
+			return nil, httpErr
+		})
+	}
+}
+
+// JSONError reads up to 4 KiB of the body of every response with a status code outside
+// of the 2xx range and passes it to extract, e.g. to unmarshal a JSON error object of
+// an API into a domain error. If extract returns an error, the response body is closed
+// and an *HTTPError carrying the status, header, body and the extracted error is
+// returned instead of the response. The extracted error can be retrieved with
+// errors.As. Otherwise the body is restored, so it can still be read in full.
+func JSONError(extract func(body []byte) error) TripFunc {
+	if extract == nil {
+		panic("trip: error extract function is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := send(t, r)
+			if err != nil || resp.StatusCode >= 200 && resp.StatusCode < 300 || resp.Body == nil {
+				return resp, err
+			}
+
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			if err := extract(body); err != nil {
+				drain(resp, defaultDrainLimit)
+				return nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: body, Err: err}
+			}
+			resp.Body = teeBody{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+			return resp, nil
+		})
+	}
+}
+
+// WrapError prefixes errors of failed requests with the request method and URL.
+// The original error is wrapped and can be retrieved with errors.Is and errors.As.
+//
+// Example message:
+//
+//	POST http://example.com/endpoint: network error
+func WrapError() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := t.RoundTrip(r)
+			if err != nil {
+				err = fmt.Errorf("%s %s: %w", r.Method, r.URL, err)
+			}
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package trip_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestStatusError(t *testing.T) {
+	isError := func(code int) bool { return code >= 400 }
+
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{Status: "404 Not Found", StatusCode: 404, Body: io.NopCloser(strings.NewReader("not found"))}, nil
+	}, trip.StatusError(isError))
+
+	var httpErr *trip.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("got: %v, expected: *trip.HTTPError", err)
+	}
+	assertEqual(t, resp, nil)
+	assertEqual(t, httpErr.StatusCode, 404)
+	assertEqual(t, httpErr.Status, "404 Not Found")
+	assertEqual(t, string(httpErr.Body), "not found")
+}
+
+func TestStatusErrorBodyCapped(t *testing.T) {
+	isError := func(code int) bool { return code >= 400 }
+
+	_, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 500, Body: io.NopCloser(strings.NewReader(strings.Repeat("x", 1<<20)))}, nil
+	}, trip.StatusError(isError))
+
+	var httpErr *trip.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("got: %v, expected: *trip.HTTPError", err)
+	}
+	assertEqual(t, len(httpErr.Body), 4<<10)
+	assertEqual(t, httpErr.Error(), "trip: unexpected status 500 Internal Server Error")
+}
+
+func TestStatusErrorSuccess(t *testing.T) {
+	isError := func(code int) bool { return code >= 400 }
+
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	}, trip.StatusError(isError))
+
+	if err != nil {
+		t.Fatalf("got: %v, expected no error", err)
+	}
+	assertEqual(t, resp.StatusCode, 200)
+}
+
+func TestWrapError(t *testing.T) {
+	errNetwork := errors.New("network error")
+
+	_, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errNetwork
+	}, trip.WrapError())
+
+	assertEqual(t, err.Error(), "POST http://example.com/foo?bar=yes: network error")
+	assertEqual(t, errors.Unwrap(err), errNetwork)
+}
+
+func TestWrapErrorSuccess(t *testing.T) {
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200}, nil
+	}, trip.WrapError())
+
+	assertEqual(t, resp.StatusCode, 200)
+	assertEqual(t, err, nil)
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string { return e.Code + ": " + e.Message }
+
+func TestJSONError(t *testing.T) {
+	const body = `{"error":{"code":"rate_limited","message":"slow down"}}`
+	extract := func(b []byte) error {
+		var payload struct {
+			Error *apiError `json:"error"`
+		}
+		if err := json.Unmarshal(b, &payload); err != nil || payload.Error == nil {
+			return nil
+		}
+		return payload.Error
+	}
+
+	respBody := &countingBody{Reader: strings.NewReader(body)}
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 429,
+			Header:     http.Header{"Retry-After": {"10"}},
+			Body:       respBody,
+		}, nil
+	}, trip.JSONError(extract))
+
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got: %v, expected: *apiError", err)
+	}
+	assertEqual(t, apiErr.Code, "rate_limited")
+	assertEqual(t, apiErr.Message, "slow down")
+
+	var httpErr *trip.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("got: %v, expected: *trip.HTTPError", err)
+	}
+	assertEqual(t, httpErr.StatusCode, 429)
+	assertEqual(t, httpErr.Header.Get("Retry-After"), "10")
+	assertEqual(t, string(httpErr.Body), body)
+	assertEqual(t, resp, nil)
+	assertEqual(t, respBody.closed, true)
+}
+
+func TestJSONErrorLimit(t *testing.T) {
+	body := strings.Repeat("x", 10000)
+	var extracted int
+
+	resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 500, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}, trip.JSONError(func(b []byte) error {
+		extracted = len(b)
+		return nil
+	}))
+
+	assertEqual(t, extracted, 4096)
+	restored, _ := io.ReadAll(resp.Body)
+	assertEqual(t, string(restored), body)
+}
+
+func TestJSONErrorSuccess(t *testing.T) {
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"error":"none"}`))}, nil
+	}, trip.JSONError(func([]byte) error { return errors.New("unexpected call") }))
+
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.StatusCode, 200)
+}
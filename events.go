@@ -0,0 +1,78 @@
+package trip
+
+import (
+	"net/http"
+	"time"
+)
+
+// EventType is the lifecycle stage of a request reported by an Event.
+type EventType int
+
+const (
+	// EventStart is sent before a request is handed to the transport.
+	EventStart EventType = iota
+
+	// EventFinish is sent when a response was received.
+	EventFinish
+
+	// EventError is sent when a request failed with an error.
+	EventError
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventStart:
+		return "start"
+	case EventFinish:
+		return "finish"
+	case EventError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Event describes a lifecycle stage of a request. StatusCode and Duration are only set
+// for EventFinish and EventError, Err only for EventError.
+type Event struct {
+	Type       EventType
+	Method     string
+	URL        string
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Events sends an Event to ch when a request starts, and when it finishes or fails.
+// Sending never blocks the request: if ch is full, the event is dropped. Use a
+// buffered channel sized for the expected burst of requests to avoid losing events.
+func Events(ch chan<- Event) TripFunc {
+	if ch == nil {
+		panic("trip: event channel is nil")
+	}
+	emit := func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			url := r.URL.String()
+			emit(Event{Type: EventStart, Method: r.Method, URL: url})
+			start := clk.Now()
+
+			resp, err := send(t, r)
+			e := Event{Type: EventFinish, Method: r.Method, URL: url, Duration: since(start)}
+			if err != nil {
+				e.Type, e.Err = EventError, err
+			} else {
+				e.StatusCode = resp.StatusCode
+			}
+			emit(e)
+
+			return resp, err
+		})
+	}
+}
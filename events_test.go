@@ -0,0 +1,60 @@
+package trip_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestEvents(t *testing.T) {
+	clock := useFakeClock(t)
+	events := make(chan trip.Event, 2)
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		clock.Advance(5 * time.Millisecond)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}, trip.Events(events))
+
+	start := <-events
+	assertEqual(t, start.Type, trip.EventStart)
+	assertEqual(t, start.Method, "POST")
+	assertEqual(t, start.URL, "http://example.com/foo?bar=yes")
+
+	finish := <-events
+	assertEqual(t, finish.Type, trip.EventFinish)
+	assertEqual(t, finish.StatusCode, 200)
+	assertEqual(t, finish.Duration, 5*time.Millisecond)
+	assertEqual(t, finish.Err, nil)
+}
+
+func TestEventsError(t *testing.T) {
+	events := make(chan trip.Event, 2)
+	errNetwork := errors.New("network error")
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errNetwork
+	}, trip.Events(events))
+
+	<-events
+	e := <-events
+	assertEqual(t, e.Type, trip.EventError)
+	assertEqual(t, e.Err, errNetwork)
+	assertEqual(t, e.Type.String(), "error")
+}
+
+func TestEventsDropWhenFull(t *testing.T) {
+	events := make(chan trip.Event, 1)
+
+	_, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}, trip.Events(events))
+
+	assertEqual(t, err, nil)
+	assertEqual(t, len(events), 1)
+	assertEqual(t, (<-events).Type, trip.EventStart)
+}
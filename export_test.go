@@ -0,0 +1,32 @@
+package trip
+
+import "strings"
+
+// SetClock replaces the clock used by time based trips and returns
+// a function restoring the previous one.
+func SetClock(c clock) (restore func()) {
+	prev := clk
+	clk = c
+	return func() { clk = prev }
+}
+
+// UnregisterDecoder removes the decoder registered for encoding by RegisterDecoder.
+func UnregisterDecoder(encoding string) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	delete(decoders, strings.ToLower(encoding))
+}
+
+// UnregisterCharset removes the decoder registered for charset by RegisterCharset.
+func UnregisterCharset(charset string) {
+	charsetsMu.Lock()
+	defer charsetsMu.Unlock()
+	delete(charsets, strings.ToLower(charset))
+}
+
+// UnregisterTransport removes the transport registered under name by Register.
+func UnregisterTransport(name string) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	delete(transports, name)
+}
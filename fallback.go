@@ -0,0 +1,29 @@
+package trip
+
+import "net/http"
+
+// Fallback calls f when a request fails with an error or a response with one of the
+// given status codes, for example to serve a cached or default response while an
+// upstream is unavailable. f receives the request and the failed result and returns
+// the result passed to the caller instead. If f doesn't return the failed response,
+// it should close its body. Placed after Retry in the list of trip functions, f is
+// only called once all attempts have failed.
+func Fallback(f func(*http.Request, *http.Response, error) (*http.Response, error), statusCodes ...int) TripFunc {
+	if f == nil {
+		panic("trip: fallback function is nil")
+	}
+	failed := map[int]bool{}
+	for _, code := range statusCodes {
+		failed[code] = true
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := send(t, r)
+			if err != nil || failed[resp.StatusCode] {
+				return f(r, resp, err)
+			}
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package trip_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestFallback(t *testing.T) {
+	useFakeClock(t)
+
+	calls := 0
+	var fallbackErr error
+	fallback := func(r *http.Request, resp *http.Response, err error) (*http.Response, error) {
+		fallbackErr = err
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("stale"))}, nil
+	}
+
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, errors.New("network error")
+	}, trip.Retry(3, time.Second), trip.Fallback(fallback))
+
+	assertEqual(t, err, nil)
+	assertEqual(t, calls, 3)
+	assertEqual(t, fallbackErr.Error(), "network error")
+
+	body, _ := io.ReadAll(resp.Body)
+	assertEqual(t, resp.StatusCode, 200)
+	assertEqual(t, string(body), "stale")
+}
+
+func TestFallbackStatusCodes(t *testing.T) {
+	fallbacks := 0
+	fallback := func(r *http.Request, resp *http.Response, err error) (*http.Response, error) {
+		fallbacks++
+		resp.Body.Close()
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}
+
+	for _, tt := range []struct{ status, expected int }{{503, 200}, {404, 404}} {
+		resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: tt.status, Body: http.NoBody}, nil
+		}, trip.Fallback(fallback, http.StatusServiceUnavailable))
+
+		assertEqual(t, resp.StatusCode, tt.expected)
+	}
+	assertEqual(t, fallbacks, 1)
+}
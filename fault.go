@@ -0,0 +1,81 @@
+package trip
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is the error returned by FaultInject if FaultConfig.Err is nil.
+var ErrInjectedFault = errors.New("trip: injected fault")
+
+// FaultConfig configures the faults injected by FaultInject.
+type FaultConfig struct {
+	// ErrorRate is the probability between 0 and 1 of failing a request with Err.
+	ErrorRate float64
+	// Err is the error returned for failed requests. Defaults to ErrInjectedFault.
+	Err error
+
+	// StatusRate is the probability between 0 and 1 of responding with StatusCode
+	// instead of sending the request.
+	StatusRate float64
+	// StatusCode is the status code of injected responses. Defaults to 503.
+	StatusCode int
+
+	// Latency is added to every request before it is sent.
+	Latency time.Duration
+
+	// Rand is the source of randomness. If nil, the source set by SetRandReader is used.
+	Rand *rand.Rand
+}
+
+// FaultInject injects errors, failure responses and latency into requests as described
+// by config. It is intended for testing how clients cope with failures.
+func FaultInject(config FaultConfig) TripFunc {
+	if config.Err == nil {
+		config.Err = ErrInjectedFault
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = http.StatusServiceUnavailable
+	}
+
+	var mu sync.Mutex
+	roll := func(rate float64) bool {
+		if rate <= 0 {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if config.Rand != nil {
+			return config.Rand.Float64() < rate
+		}
+		return randFloat64() < rate
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if err := sleep(r.Context(), config.Latency); err != nil {
+				return nil, err
+			}
+			if roll(config.ErrorRate) {
+				return nil, config.Err
+			}
+			if roll(config.StatusRate) {
+				return &http.Response{
+					Status:     strconv.Itoa(config.StatusCode) + " " + http.StatusText(config.StatusCode),
+					StatusCode: config.StatusCode,
+					Proto:      "HTTP/1.1",
+					ProtoMajor: 1,
+					ProtoMinor: 1,
+					Header:     http.Header{},
+					Body:       http.NoBody,
+					Request:    r,
+				}, nil
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
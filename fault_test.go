@@ -0,0 +1,63 @@
+package trip_test
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestFaultInjectError(t *testing.T) {
+	calls := 0
+	config := trip.FaultConfig{ErrorRate: 1}
+
+	for i := 0; i < 10; i++ {
+		_, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return nil, nil
+		}, trip.FaultInject(config))
+		assertEqual(t, errors.Is(err, trip.ErrInjectedFault), true)
+	}
+
+	assertEqual(t, calls, 0)
+}
+
+func TestFaultInjectStatus(t *testing.T) {
+	config := trip.FaultConfig{StatusRate: 1, StatusCode: http.StatusTooManyRequests}
+
+	for i := 0; i < 10; i++ {
+		resp, _ := roundTrip(noop, trip.FaultInject(config))
+		assertEqual(t, resp.StatusCode, http.StatusTooManyRequests)
+		assertEqual(t, resp.Status, "429 Too Many Requests")
+	}
+}
+
+func TestFaultInjectLatency(t *testing.T) {
+	clock := useFakeClock(t)
+	config := trip.FaultConfig{Latency: 50 * time.Millisecond}
+
+	roundTrip(noop, trip.FaultInject(config))
+
+	assertEqual(t, len(clock.Sleeps()), 1)
+	assertEqual(t, clock.Sleeps()[0], 50*time.Millisecond)
+}
+
+func TestFaultInjectSeed(t *testing.T) {
+	run := func() []bool {
+		var faults []bool
+		config := trip.FaultConfig{ErrorRate: 0.5, Rand: rand.New(rand.NewSource(1))}
+		for i := 0; i < 20; i++ {
+			_, err := roundTrip(noop, trip.FaultInject(config))
+			faults = append(faults, err != nil)
+		}
+		return faults
+	}
+
+	a, b := run(), run()
+	for i := range a {
+		assertEqual(t, a[i], b[i])
+	}
+}
@@ -0,0 +1,377 @@
+package trip
+
+import (
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger logs every request using the provided log function.
+// Any function that matches the printf signature can be used like log.Printf
+// or similar functions from popular packages like zap, zerolog, logrus, etc.
+// Logger should be placed before Retry in the list of trip functions, so that every
+// attempt is logged on its own line. When placed after Retry instead, so that it
+// wraps it, the whole request is logged once and requests that took more than one
+// attempt are logged with the total number of attempts made by Retry. The number of
+// the current attempt is logged by LoggerPerAttempt.
+//
+// Output examples:
+//
+//	POST http://example.com/endpoint?key=value - 200 OK - 12.34ms
+//	POST http://example.com/endpoint?key=value - error:"network error" - 12.34ms
+//	POST http://example.com/endpoint?key=value - 200 OK - 12.34ms - attempts=3
+func Logger(f func(format string, v ...any)) TripFunc {
+	return logger(logConfig{fields: []logField{attemptsField}, write: logLine(f)})
+}
+
+// SampleConfig configures the sampling of LoggerSampleWith.
+type SampleConfig struct {
+	// Fraction is the fraction between 0 and 1 of requests that are logged.
+	Fraction float64
+
+	// AlwaysLogErrors logs all failed requests, regardless of the sampling.
+	AlwaysLogErrors bool
+
+	// Rand is the source of randomness. If nil, the source set by SetRandReader is used.
+	Rand *rand.Rand
+}
+
+// LoggerSample works like Logger, but only logs a random fraction of requests.
+func LoggerSample(f func(format string, v ...any), fraction float64) TripFunc {
+	return LoggerSampleWith(f, SampleConfig{Fraction: fraction})
+}
+
+// LoggerSampleWith works like LoggerSample, but the sampling is configured by config.
+func LoggerSampleWith(f func(format string, v ...any), config SampleConfig) TripFunc {
+	var mu sync.Mutex
+	sample := func() bool {
+		if config.Fraction <= 0 {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if config.Rand != nil {
+			return config.Rand.Float64() < config.Fraction
+		}
+		return randFloat64() < config.Fraction
+	}
+
+	return logger(logConfig{
+		sample: func(err error) bool {
+			return sample() || err != nil && config.AlwaysLogErrors
+		},
+		write: logLine(f),
+	})
+}
+
+// LoggerPerAttempt works like Logger, but adds the attempt number to every line.
+// It should be placed before Retry in the list of trip functions, so that every
+// attempt of a retried request is logged.
+//
+// Output examples:
+//
+//	POST http://example.com/endpoint?key=value - 502 Bad Gateway - 12.34ms - attempt=1
+//	POST http://example.com/endpoint?key=value - 200 OK - 12.34ms - attempt=2
+func LoggerPerAttempt(f func(format string, v ...any)) TripFunc {
+	return logger(logConfig{fields: []logField{attemptField}, write: logLine(f)})
+}
+
+// LoggerTrace works like Logger, but additionally logs the time spent on DNS lookup,
+// connecting, TLS handshake and waiting for the first response byte. Phases that
+// did not happen, e.g. because a connection was reused, are logged as 0s.
+//
+// Output example:
+//
+//	GET https://example.com/ - 200 OK - 52.1ms - dns=3.2ms connect=10.4ms tls=21.7ms ttfb=50.3ms
+func LoggerTrace(f func(format string, v ...any)) TripFunc {
+	return logger(logConfig{fields: []logField{traceField}, write: logLine(f)})
+}
+
+// traceField logs the timings of the connection phases of a request, as logged by
+// LoggerTrace.
+func traceField(r *http.Request) (*http.Request, func(e *logEntry) string) {
+	var (
+		mu                               sync.Mutex
+		dnsStart, connectStart, tlsStart time.Time
+		dns, connect, tlsHandshake, ttfb time.Duration
+		start                            = clk.Now()
+	)
+
+	measure := func(from *time.Time, d *time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !from.IsZero() {
+			*d = since(*from)
+		}
+	}
+	mark := func(t *time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		*t = clk.Now()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { mark(&dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { measure(&dnsStart, &dns) },
+		ConnectStart:         func(string, string) { mark(&connectStart) },
+		ConnectDone:          func(string, string, error) { measure(&connectStart, &connect) },
+		TLSHandshakeStart:    func() { mark(&tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { measure(&tlsStart, &tlsHandshake) },
+		GotFirstResponseByte: func() { measure(&start, &ttfb) },
+	}
+
+	return r.WithContext(httptrace.WithClientTrace(r.Context(), trace)), func(*logEntry) string {
+		mu.Lock()
+		defer mu.Unlock()
+		return fmt.Sprintf("dns=%v connect=%v tls=%v ttfb=%v", dns, connect, tlsHandshake, ttfb)
+	}
+}
+
+// LoggerOutcome works like Logger, but adds the outcome category of every request,
+// which is `ok` for status codes below 400, `client_error` for 4xx, `server_error` for
+// 5xx and `network_error` for requests that failed with an error.
+//
+// Output examples:
+//
+//	GET http://example.com/missing - 404 Not Found - 12.34ms - outcome=client_error
+//	GET http://example.com/ - error:"network error" - 12.34ms - outcome=network_error
+func LoggerOutcome(f func(format string, v ...any)) TripFunc {
+	return logger(logConfig{fields: []logField{outcomeField}, write: logLine(f)})
+}
+
+// outcomeField logs the outcome category of a request.
+func outcomeField(r *http.Request) (*http.Request, func(e *logEntry) string) {
+	return r, func(e *logEntry) string {
+		return "outcome=" + outcome(e.resp, e.err)
+	}
+}
+
+// outcome returns the outcome category of a request as logged by LoggerOutcome.
+func outcome(resp *http.Response, err error) string {
+	switch {
+	case err != nil:
+		return "network_error"
+	case resp.StatusCode >= 500:
+		return "server_error"
+	case resp.StatusCode >= 400:
+		return "client_error"
+	}
+	return "ok"
+}
+
+// LoggerServerTiming works like Logger, but adds the durations reported by the
+// `Server-Timing` response header, so that client and server timings appear side by
+// side. Entries without a name or a valid duration are skipped.
+//
+// Output example:
+//
+//	GET http://example.com/ - 200 OK - 70.12ms - server-timing: db=53.2ms app=12.1ms
+func LoggerServerTiming(f func(format string, v ...any)) TripFunc {
+	return logger(logConfig{fields: []logField{serverTimingField}, write: logLine(f)})
+}
+
+// serverTimingField logs the durations reported by the `Server-Timing` header of a
+// response, if any.
+func serverTimingField(r *http.Request) (*http.Request, func(e *logEntry) string) {
+	return r, func(e *logEntry) string {
+		if e.err != nil {
+			return ""
+		}
+		if entries := serverTimings(e.resp.Header); len(entries) > 0 {
+			return "server-timing: " + strings.Join(entries, " ")
+		}
+		return ""
+	}
+}
+
+// serverTimings parses the `Server-Timing` header into entries of the form
+// `name=durms`, skipping malformed ones.
+func serverTimings(h http.Header) []string {
+	var entries []string
+	for _, v := range h.Values("Server-Timing") {
+		for _, metric := range strings.Split(v, ",") {
+			params := strings.Split(metric, ";")
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			for _, p := range params[1:] {
+				key, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "dur") {
+					continue
+				}
+				dur, err := strconv.ParseFloat(strings.Trim(strings.TrimSpace(value), `"`), 64)
+				if err != nil {
+					break
+				}
+				entries = append(entries, name+"="+strconv.FormatFloat(dur, 'f', -1, 64)+"ms")
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// LoggerFormat works like Logger, but formats the log lines using the given templates
+// for successful and failed requests. Templates may contain the placeholders
+// `{method}`, `{url}`, `{status}`, `{status_code}`, `{duration}`, `{error}` and
+// `{outcome}`, the outcome category as logged by LoggerOutcome. Unknown placeholders
+// are left as-is.
+//
+// Example:
+//
+//	trip.LoggerFormat(log.Printf, "{method} {url} -> {status_code} in {duration}", "{method} {url} failed: {error}")
+func LoggerFormat(f func(format string, v ...any), successFmt, errorFmt string) TripFunc {
+	if f == nil {
+		panic("trip: log function is nil")
+	}
+	return logger(logConfig{write: func(e *logEntry) {
+		values := []string{
+			"{method}", e.req.Method,
+			"{url}", e.req.URL.String(),
+			"{duration}", e.duration.String(),
+			"{outcome}", outcome(e.resp, e.err),
+		}
+		format := successFmt
+		if e.err != nil {
+			format = errorFmt
+			values = append(values, "{error}", e.err.Error(), "{status}", "", "{status_code}", "")
+		} else {
+			values = append(values, "{error}", "", "{status}", e.resp.Status, "{status_code}", strconv.Itoa(e.resp.StatusCode))
+		}
+		f("%s", strings.NewReplacer(values...).Replace(format))
+	}})
+}
+
+// LogFields passes the fields of every request to sink, for structured loggers that
+// don't need a format string. The fields are `method` and `url` as string,
+// `status_code` as int, `duration_ms` as float64 and `error` as string. For failed
+// requests, `status_code` is 0; for successful ones, `error` is empty.
+// LogFields should be placed before Retry in the list of trip functions.
+func LogFields(sink func(fields map[string]any)) TripFunc {
+	if sink == nil {
+		panic("trip: log sink is nil")
+	}
+	return logger(logConfig{write: func(e *logEntry) {
+		fields := map[string]any{
+			"method":      e.req.Method,
+			"url":         e.req.URL.String(),
+			"status_code": 0,
+			"duration_ms": float64(e.duration) / float64(time.Millisecond),
+			"error":       "",
+		}
+		if e.err != nil {
+			fields["error"] = e.err.Error()
+		} else {
+			fields["status_code"] = e.resp.StatusCode
+		}
+		sink(fields)
+	}})
+}
+
+// logConfig configures logger, which all logging trip functions are built on.
+type logConfig struct {
+	// fields are added to the log line of every request, in order.
+	fields []logField
+
+	// sample reports whether a request that failed with err is logged. If nil, all
+	// requests are logged.
+	sample func(err error) bool
+
+	// write outputs the log entry of a request.
+	write func(e *logEntry)
+}
+
+// logField is called before a request is sent and returns the request to send, which
+// may carry additional context. The returned function is called once the request is
+// done and returns the field to add to the log line, or an empty string to add none.
+type logField func(r *http.Request) (*http.Request, func(e *logEntry) string)
+
+// logEntry is a request as logged by logger.
+type logEntry struct {
+	req      *http.Request
+	resp     *http.Response
+	err      error
+	duration time.Duration
+	fields   []string
+}
+
+// logger measures every request and passes the result to c.write, unless the request
+// is skipped by c.sample or logging is disabled for it by Disable.
+func logger(c logConfig) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if isDisabled(r.Context(), "logger") {
+				return t.RoundTrip(r)
+			}
+			start := clk.Now()
+			req := r
+			done := make([]func(e *logEntry) string, len(c.fields))
+			for i, field := range c.fields {
+				req, done[i] = field(req)
+			}
+
+			resp, err := send(t, req)
+			if c.sample != nil && !c.sample(err) {
+				return resp, err
+			}
+			e := &logEntry{req: r, resp: resp, err: err, duration: since(start)}
+			for _, field := range done {
+				if v := field(e); v != "" {
+					e.fields = append(e.fields, v)
+				}
+			}
+			c.write(e)
+
+			return resp, err
+		})
+	}
+}
+
+// logLine returns a write function for logger that logs entries with f in the format
+// of Logger, followed by their fields.
+func logLine(f func(format string, v ...any)) func(e *logEntry) {
+	if f == nil {
+		panic("trip: log function is nil")
+	}
+	return func(e *logEntry) {
+		var suffix string
+		for _, field := range e.fields {
+			suffix += " - " + field
+		}
+		if e.err != nil {
+			f("%s %s - error:%q - %v%s", e.req.Method, e.req.URL.String(), e.err.Error(), e.duration, suffix)
+		} else {
+			f("%s %s - %s - %v%s", e.req.Method, e.req.URL.String(), e.resp.Status, e.duration, suffix)
+		}
+	}
+}
+
+// attemptsField logs the number of attempts made by a Retry wrapped by the logger, if
+// there was more than one.
+func attemptsField(r *http.Request) (*http.Request, func(e *logEntry) string) {
+	ctx, counter := withAttemptCounter(r.Context())
+	return r.WithContext(ctx), func(*logEntry) string {
+		if attempts := int(counter.n.Load()); attempts > 1 {
+			return "attempts=" + strconv.Itoa(attempts)
+		}
+		return ""
+	}
+}
+
+// attemptField logs the number of the current attempt of a Retry wrapping the logger.
+func attemptField(r *http.Request) (*http.Request, func(e *logEntry) string) {
+	attempt, ok := AttemptFromContext(r.Context())
+	if !ok {
+		attempt = 1
+	}
+	return r, func(*logEntry) string {
+		return "attempt=" + strconv.Itoa(attempt)
+	}
+}
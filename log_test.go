@@ -0,0 +1,337 @@
+package trip_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestLoggerAttempts(t *testing.T) {
+	useFakeClock(t)
+
+	var lines []string
+	logf := func(format string, v ...any) {
+		lines = append(lines, fmt.Sprintf(format, v...))
+	}
+
+	calls := 0
+	flaky := func(r *http.Request) (*http.Response, error) {
+		calls++
+		if calls < 3 {
+			return &http.Response{Status: "502 Bad Gateway", StatusCode: 502, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{Status: "200 OK", StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+
+	roundTrip(flaky, trip.Retry(3, time.Second, trip.RetryableStatusCodes...), trip.Logger(logf))
+
+	assertEqual(t, len(lines), 1)
+	assertPrefix(t, lines[0], "POST http://example.com/foo?bar=yes - 200 OK -")
+	assertSuffix(t, lines[0], " - attempts=3")
+
+	lines, calls = nil, 0
+	roundTrip(flaky, trip.Logger(logf), trip.Retry(3, time.Second, trip.RetryableStatusCodes...))
+
+	assertEqual(t, len(lines), 3)
+	for _, line := range lines {
+		assertEqual(t, strings.Contains(line, "attempts="), false)
+	}
+}
+
+func TestLoggerAttemptsWithObserve(t *testing.T) {
+	useFakeClock(t)
+
+	var (
+		line string
+		stat trip.Stat
+	)
+	logf := func(format string, v ...any) {
+		line = fmt.Sprintf(format, v...)
+	}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("network error")
+	}, trip.Retry(3, time.Second), trip.Logger(logf), trip.Observe(func(s trip.Stat) { stat = s }))
+
+	assertSuffix(t, line, " - attempts=3")
+	assertEqual(t, stat.Retries, 2)
+}
+
+func TestLoggerDisabled(t *testing.T) {
+	logged := 0
+	logf := func(format string, v ...any) { logged++ }
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}), trip.Logger(logf), trip.LoggerOutcome(logf))
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	transport.RoundTrip(req.WithContext(trip.Disable(req.Context(), "logger")))
+	assertEqual(t, logged, 0)
+
+	transport.RoundTrip(req)
+	assertEqual(t, logged, 2)
+}
+
+func TestLoggerPerAttempt(t *testing.T) {
+	var (
+		lines    []string
+		attempts = 3
+		delay    = time.Millisecond
+	)
+
+	logf := func(format string, v ...any) {
+		lines = append(lines, fmt.Sprintf(format, v...))
+	}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{Status: "502 Bad Gateway", StatusCode: 502, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}, trip.LoggerPerAttempt(logf), trip.Retry(attempts, delay, trip.RetryableStatusCodes...))
+
+	assertEqual(t, len(lines), attempts)
+	for i, line := range lines {
+		assertPrefix(t, line, "POST http://example.com/foo?bar=yes - 502 Bad Gateway -")
+		assertSuffix(t, line, fmt.Sprintf(" - attempt=%d", i+1))
+	}
+}
+
+func TestLoggerPerAttemptWithoutRetry(t *testing.T) {
+	var line string
+
+	logf := func(format string, v ...any) {
+		line = fmt.Sprintf(format, v...)
+	}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{Status: "200 OK", StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}, trip.LoggerPerAttempt(logf))
+
+	assertSuffix(t, line, " - attempt=1")
+}
+
+func TestLoggerTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	var line string
+	logf := func(format string, v ...any) {
+		line = fmt.Sprintf(format, v...)
+	}
+
+	client := &http.Client{Transport: trip.Default(trip.LoggerTrace(logf))}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	assertPrefix(t, line, "GET "+server.URL+" - 200 OK - ")
+	matched, _ := regexp.MatchString(` - dns=\S+ connect=\S+ tls=\S+ ttfb=\S+$`, line)
+	if !matched {
+		t.Errorf("got: %v, expected it to contain timings", line)
+	}
+	assertEqual(t, strings.Contains(line, "ttfb=0s"), false)
+}
+
+func TestLoggerOutcome(t *testing.T) {
+	tests := []struct {
+		status   int
+		err      error
+		expected string
+	}{
+		{status: 200, expected: " - outcome=ok"},
+		{status: 304, expected: " - outcome=ok"},
+		{status: 404, expected: " - outcome=client_error"},
+		{status: 500, expected: " - outcome=server_error"},
+		{err: errors.New("network error"), expected: " - outcome=network_error"},
+	}
+
+	for _, tt := range tests {
+		var line string
+		logf := func(format string, v ...any) {
+			line = fmt.Sprintf(format, v...)
+		}
+
+		roundTrip(func(r *http.Request) (*http.Response, error) {
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			return &http.Response{Status: http.StatusText(tt.status), StatusCode: tt.status, Body: http.NoBody}, nil
+		}, trip.LoggerOutcome(logf))
+
+		assertSuffix(t, line, tt.expected)
+	}
+}
+
+func TestLoggerServerTiming(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{header: "db;dur=53.2, app;dur=12.1", expected: " - server-timing: db=53.2ms app=12.1ms"},
+		{header: `cache;desc="Cache Read";dur=23.2`, expected: " - server-timing: cache=23.2ms"},
+		{header: "db;dur=abc, ;dur=1, miss, app;dur=12.1", expected: " - server-timing: app=12.1ms"},
+		{header: "", expected: " - OK - 0s"},
+	}
+
+	for _, tt := range tests {
+		useFakeClock(t)
+
+		var line string
+		logf := func(format string, v ...any) {
+			line = fmt.Sprintf(format, v...)
+		}
+
+		roundTrip(func(r *http.Request) (*http.Response, error) {
+			resp := &http.Response{Status: "OK", StatusCode: 200, Header: http.Header{}, Body: http.NoBody}
+			if tt.header != "" {
+				resp.Header.Set("Server-Timing", tt.header)
+			}
+			return resp, nil
+		}, trip.LoggerServerTiming(logf))
+
+		assertSuffix(t, line, tt.expected)
+	}
+}
+
+func TestLoggerFormatOutcome(t *testing.T) {
+	var line string
+	logf := func(format string, v ...any) {
+		line = fmt.Sprintf(format, v...)
+	}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{Status: "503 Service Unavailable", StatusCode: 503, Body: http.NoBody}, nil
+	}, trip.LoggerFormat(logf, "{status_code} {outcome}", "{outcome}"))
+
+	assertEqual(t, line, "503 server_error")
+}
+
+func TestLoggerFormat(t *testing.T) {
+	var (
+		clock = useFakeClock(t)
+		line  string
+	)
+
+	logf := func(format string, v ...any) {
+		line = fmt.Sprintf(format, v...)
+	}
+	logger := trip.LoggerFormat(logf, "{method} {url} -> {status_code} ({status}) in {duration} {unknown}", "{method} {url} failed: {error}")
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		clock.Advance(25 * time.Millisecond)
+		return &http.Response{Status: "201 Created", StatusCode: 201, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}, logger)
+	assertEqual(t, line, "POST http://example.com/foo?bar=yes -> 201 (201 Created) in 25ms {unknown}")
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("network error")
+	}, logger)
+	assertEqual(t, line, "POST http://example.com/foo?bar=yes failed: network error")
+}
+
+func TestLoggerSample(t *testing.T) {
+	tests := []struct {
+		fraction float64
+		expected int
+	}{
+		{fraction: 0, expected: 0},
+		{fraction: 1, expected: 100},
+	}
+
+	for _, tt := range tests {
+		lines := 0
+		logf := func(format string, v ...any) { lines++ }
+
+		for i := 0; i < 100; i++ {
+			roundTrip(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{Status: "200 OK", StatusCode: 200}, nil
+			}, trip.LoggerSample(logf, tt.fraction))
+		}
+
+		assertEqual(t, lines, tt.expected)
+	}
+}
+
+func TestLoggerSampleWith(t *testing.T) {
+	useFakeClock(t)
+
+	run := func(config trip.SampleConfig) []string {
+		var lines []string
+		logf := func(format string, v ...any) { lines = append(lines, fmt.Sprintf(format, v...)) }
+		logger := trip.LoggerSampleWith(logf, config)
+
+		for i := 0; i < 50; i++ {
+			roundTrip(func(r *http.Request) (*http.Response, error) {
+				if i%2 == 0 {
+					return nil, errors.New("network error")
+				}
+				return &http.Response{Status: "200 OK", StatusCode: 200}, nil
+			}, logger)
+		}
+		return lines
+	}
+
+	errorsOnly := run(trip.SampleConfig{Fraction: 0, AlwaysLogErrors: true})
+	assertEqual(t, len(errorsOnly), 25)
+	for _, line := range errorsOnly {
+		assertPrefix(t, line, `POST http://example.com/foo?bar=yes - error:"network error" -`)
+	}
+
+	a := run(trip.SampleConfig{Fraction: 0.5, Rand: rand.New(rand.NewSource(1))})
+	b := run(trip.SampleConfig{Fraction: 0.5, Rand: rand.New(rand.NewSource(1))})
+	assertEqual(t, strings.Join(a, "\n"), strings.Join(b, "\n"))
+	assertNotEqual(t, len(a), 0)
+	assertNotEqual(t, len(a), 50)
+}
+
+func TestLoggerNilResponse(t *testing.T) {
+	var line string
+	logf := func(format string, v ...any) {
+		line = fmt.Sprintf(format, v...)
+	}
+
+	_, err := roundTrip(noop, trip.Logger(logf))
+
+	assertEqual(t, err, trip.ErrNilResponse)
+	assertPrefix(t, line, `POST http://example.com/foo?bar=yes - error:"trip: nil response with nil error" -`)
+}
+
+func TestLogFields(t *testing.T) {
+	clock := useFakeClock(t)
+
+	var fields map[string]any
+	sink := func(f map[string]any) {
+		fields = f
+	}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		clock.Advance(1500 * time.Microsecond)
+		return &http.Response{Status: "201 Created", StatusCode: 201, Body: http.NoBody}, nil
+	}, trip.LogFields(sink))
+
+	assertEqual(t, len(fields), 5)
+	assertEqual(t, fields["method"], any("POST"))
+	assertEqual(t, fields["url"], any("http://example.com/foo?bar=yes"))
+	assertEqual(t, fields["status_code"], any(201))
+	assertEqual(t, fields["duration_ms"], any(1.5))
+	assertEqual(t, fields["error"], any(""))
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("network error")
+	}, trip.LogFields(sink))
+
+	assertEqual(t, fields["status_code"], any(0))
+	assertEqual(t, fields["error"], any("network error"))
+}
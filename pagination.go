@@ -0,0 +1,115 @@
+package trip
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FollowPagination follows the `Link` header of paginated JSON APIs for GET requests.
+// If a response has status 200 and links to a page with rel="next", the following
+// pages are fetched as well, up to maxPages pages in total, and their JSON arrays are
+// concatenated into a single array returned as the body of the first response. The
+// `Link` header of the returned response is the one of the last page fetched, so it
+// still points to the next page if maxPages was reached.
+//
+// FollowPagination only works with APIs returning a JSON array on every page. Responses
+// of the first page that aren't a JSON array are returned as-is, while such responses
+// of later pages fail the request. If fetching a later page fails with a status other
+// than 200, that response is returned instead.
+func FollowPagination(maxPages int) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := send(t, r)
+			if err != nil || r.Method != http.MethodGet || resp.StatusCode != http.StatusOK || maxPages < 2 {
+				return resp, err
+			}
+			next := nextLink(resp.Header)
+			if next == "" {
+				return resp, err
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			var items []json.RawMessage
+			if err := json.Unmarshal(body, &items); err != nil {
+				resp.Body = io.NopCloser(bytes.NewReader(body))
+				return resp, nil
+			}
+
+			url, link := r.URL, resp.Header.Values("Link")
+			for page := 2; page <= maxPages && next != ""; page++ {
+				if url, err = url.Parse(next); err != nil {
+					return nil, fmt.Errorf("trip: invalid link to page %d: %w", page, err)
+				}
+				req := r.Clone(r.Context())
+				req.URL = url
+				req.Host = ""
+
+				pageResp, err := send(t, req)
+				if err != nil {
+					return nil, err
+				}
+				if pageResp.StatusCode != http.StatusOK {
+					return pageResp, nil
+				}
+
+				body, err := io.ReadAll(pageResp.Body)
+				pageResp.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				var pageItems []json.RawMessage
+				if err := json.Unmarshal(body, &pageItems); err != nil {
+					return nil, fmt.Errorf("trip: page %d is not a JSON array: %w", page, err)
+				}
+				items = append(items, pageItems...)
+
+				link = pageResp.Header.Values("Link")
+				next = nextLink(pageResp.Header)
+			}
+
+			merged, _ := json.Marshal(items)
+			resp.Body = io.NopCloser(bytes.NewReader(merged))
+			resp.ContentLength = int64(len(merged))
+			resp.Header.Set("Content-Length", strconv.Itoa(len(merged)))
+			resp.Header.Del("Link")
+			for _, v := range link {
+				resp.Header.Add("Link", v)
+			}
+			return resp, nil
+		})
+	}
+}
+
+// nextLink returns the target of the link with rel="next" in the `Link` header, or an
+// empty string if there is none.
+func nextLink(h http.Header) string {
+	for _, v := range h.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return strings.TrimSpace(target[1 : len(target)-1])
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package trip_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestFollowPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+			w.Write([]byte(`[{"id":1},{"id":2}]`))
+		case "2":
+			w.Header().Set("Link", `</items>; rel="first", </items?page=3>; rel="next"`)
+			w.Write([]byte(`[{"id":3}]`))
+		case "3":
+			w.Write([]byte(`[{"id":4}]`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		maxPages int
+		expected string
+		link     string
+	}{
+		{maxPages: 1, expected: `[{"id":1},{"id":2}]`, link: `</items?page=2>; rel="next"`},
+		{maxPages: 2, expected: `[{"id":1},{"id":2},{"id":3}]`, link: `</items>; rel="first", </items?page=3>; rel="next"`},
+		{maxPages: 10, expected: `[{"id":1},{"id":2},{"id":3},{"id":4}]`, link: ""},
+	}
+
+	for _, tt := range tests {
+		client := &http.Client{Transport: trip.Default(trip.FollowPagination(tt.maxPages))}
+		resp, err := client.Get(server.URL + "/items")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assertEqual(t, string(body), tt.expected)
+		assertEqual(t, resp.Header.Get("Link"), tt.link)
+	}
+}
+
+func TestFollowPaginationNotArray(t *testing.T) {
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Link", `</next>; rel="next"`)
+		return &http.Response{StatusCode: 200, Header: header, Body: io.NopCloser(strings.NewReader(`{"id":1}`))}, nil
+	}), trip.FollowPagination(5))
+
+	resp, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	assertEqual(t, string(body), `{"id":1}`)
+}
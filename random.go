@@ -0,0 +1,64 @@
+package trip
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// randReader holds the reader set by SetRandReader. If nil, crypto/rand is read
+// directly, so that normal reads don't contend on a lock.
+var randReader atomic.Pointer[lockedReader]
+
+// lockedReader serializes reads of a reader set by SetRandReader, which is not
+// necessarily safe for concurrent use.
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return io.ReadFull(l.r, b)
+}
+
+// SetRandReader replaces the source of randomness used for generated keys and IDs,
+// jittered backoffs and sampling, which defaults to crypto/rand. A nil reader restores
+// the default. It is intended to make tests deterministic and must not be used in
+// production, as generated idempotency keys and trace IDs become predictable.
+// Randomness configured explicitly, like SampleConfig.Rand, is not affected.
+func SetRandReader(r io.Reader) {
+	if r == nil {
+		randReader.Store(nil)
+		return
+	}
+	randReader.Store(&lockedReader{r: r})
+}
+
+// readRand fills b from the source of randomness set by SetRandReader.
+func readRand(b []byte) {
+	if l := randReader.Load(); l != nil {
+		l.Read(b)
+		return
+	}
+	rand.Read(b)
+}
+
+func randUint64() uint64 {
+	var buf [8]byte
+	readRand(buf[:])
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+// randInt63n returns a random number in [0, n).
+func randInt63n(n int64) int64 {
+	return int64(randUint64()>>1) % n
+}
+
+// randFloat64 returns a random number in [0, 1).
+func randFloat64() float64 {
+	return float64(randUint64()>>11) / (1 << 53)
+}
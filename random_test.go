@@ -0,0 +1,31 @@
+package trip_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func useRandReader(t *testing.T, b []byte) {
+	trip.SetRandReader(bytes.NewReader(b))
+	t.Cleanup(func() { trip.SetRandReader(nil) })
+}
+
+func TestSetRandReader(t *testing.T) {
+	useRandReader(t, bytes.Repeat([]byte{0xab}, 16))
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Idempotency-Key"), strings.Repeat("ab", 16))
+		return nil, nil
+	}, trip.IdempotencyKey())
+}
+
+func TestSetRandReaderJitter(t *testing.T) {
+	useRandReader(t, make([]byte, 8))
+
+	assertEqual(t, trip.FullJitterBackoff(time.Second, time.Minute).Next(3), time.Duration(0))
+}
@@ -0,0 +1,161 @@
+package trip
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimitPerHost limits the number of requests per second sent to each host, allowing
+// bursts of up to burst requests. Every host has its own budget. Requests exceeding the
+// limit wait until they are allowed to be sent or their context is done. The budgets of
+// hosts that haven't been sent requests for long enough to refill them are discarded,
+// so that talking to many hosts doesn't grow memory without bound.
+func RateLimitPerHost(rps float64, burst int) TripFunc {
+	if rps <= 0 {
+		panic("trip: rate limit must be positive")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	refill := time.Duration(float64(burst) / rps * float64(time.Second))
+
+	var (
+		mu       sync.Mutex
+		limiters = map[string]*limiter{}
+		swept    time.Time
+	)
+
+	// reserve is called with the map locked, so that a limiter can't be discarded
+	// between being looked up and reserved from.
+	reserve := func(host string) (*limiter, time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := clk.Now()
+		if now.Sub(swept) >= refill {
+			for h, l := range limiters {
+				if l.full(now) {
+					delete(limiters, h)
+				}
+			}
+			swept = now
+		}
+		l, ok := limiters[host]
+		if !ok {
+			l = &limiter{rate: rps, burst: float64(burst)}
+			limiters[host] = l
+		}
+		return l, l.reserve()
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			l, d := reserve(r.URL.Host)
+			if err := sleep(r.Context(), d); err != nil {
+				l.cancel()
+				return nil, err
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// BackpressureByHost holds back requests to a host that asked for a pause. When a
+// response with status 429 Too Many Requests or 503 Service Unavailable carries a
+// `Retry-After` header, subsequent requests to the same host wait until the requested
+// time has passed or their context is done.
+func BackpressureByHost() TripFunc {
+	var (
+		mu    sync.Mutex
+		until = map[string]time.Time{}
+	)
+
+	wait := func(host string) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		t, ok := until[host]
+		if !ok {
+			return 0
+		}
+		d := t.Sub(clk.Now())
+		if d <= 0 {
+			delete(until, host)
+		}
+		return d
+	}
+
+	pause := func(host string, d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		if t := clk.Now().Add(d); t.After(until[host]) {
+			until[host] = t
+		}
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if err := sleep(r.Context(), wait(r.URL.Host)); err != nil {
+				return nil, err
+			}
+
+			resp, err := send(t, r)
+			if err != nil {
+				return resp, err
+			}
+
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+					pause(r.URL.Host, d)
+				}
+			}
+			return resp, err
+		})
+	}
+}
+
+// limiter is a token bucket refilled at rate tokens per second.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token from the bucket and returns how long to wait until it's available.
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := clk.Now()
+	if l.last.IsZero() {
+		l.tokens = l.burst
+	} else if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// full reports whether the bucket has been refilled completely at now, so that it is
+// no different from a new one.
+func (l *limiter) full(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last.IsZero() || l.tokens+now.Sub(l.last).Seconds()*l.rate >= l.burst
+}
+
+// cancel returns a reserved token that was not used.
+func (l *limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+}
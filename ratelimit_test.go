@@ -0,0 +1,166 @@
+package trip_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestRateLimitPerHost(t *testing.T) {
+	var (
+		clock = useFakeClock(t)
+		hosts []string
+	)
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		hosts = append(hosts, r.URL.Host)
+		return nil, nil
+	}), trip.RateLimitPerHost(10, 1))
+
+	for _, host := range []string{"a.example.com", "a.example.com", "b.example.com", "b.example.com", "a.example.com"} {
+		transport.RoundTrip(httptest.NewRequest("GET", "http://"+host+"/", nil))
+	}
+
+	assertEqual(t, len(hosts), 5)
+
+	sleeps := clock.Sleeps()
+	assertEqual(t, len(sleeps), 2)
+	assertEqual(t, sleeps[0], 100*time.Millisecond)
+	assertEqual(t, sleeps[1], 100*time.Millisecond)
+}
+
+func TestRateLimitPerHostContext(t *testing.T) {
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, nil
+	}), trip.RateLimitPerHost(0.001, 1))
+
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil).WithContext(ctx))
+
+	assertEqual(t, calls, 1)
+	assertEqual(t, errors.Is(err, context.DeadlineExceeded), true)
+}
+
+func TestRateLimitPerHostIdle(t *testing.T) {
+	clock := useFakeClock(t)
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, nil
+	}), trip.RateLimitPerHost(1, 1))
+	send := func(host string) {
+		transport.RoundTrip(httptest.NewRequest("GET", "http://"+host+"/", nil))
+	}
+
+	// The refilled budget of a.example.com is discarded, the used up budget of
+	// b.example.com is kept.
+	send("a.example.com")
+	clock.Advance(2 * time.Second)
+	send("b.example.com")
+	send("b.example.com")
+	send("a.example.com")
+	send("b.example.com")
+
+	sleeps := clock.Sleeps()
+	assertEqual(t, len(sleeps), 2)
+	assertEqual(t, sleeps[0], time.Second)
+	assertEqual(t, sleeps[1], time.Second)
+}
+
+func TestBackpressureByHost(t *testing.T) {
+	var (
+		clock = useFakeClock(t)
+		calls []time.Time
+	)
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls = append(calls, clock.Now())
+		if r.URL.Path == "/limited" {
+			header := http.Header{}
+			header.Set("Retry-After", "2")
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: header}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+	}), trip.BackpressureByHost())
+
+	start := clock.Now()
+	transport.RoundTrip(httptest.NewRequest("GET", "http://a.example.com/limited", nil))
+	transport.RoundTrip(httptest.NewRequest("GET", "http://b.example.com/", nil))
+	transport.RoundTrip(httptest.NewRequest("GET", "http://a.example.com/", nil))
+	transport.RoundTrip(httptest.NewRequest("GET", "http://a.example.com/", nil))
+
+	assertEqual(t, len(calls), 4)
+	assertEqual(t, calls[0].Sub(start), time.Duration(0))
+	assertEqual(t, calls[1].Sub(start), time.Duration(0))
+	assertEqual(t, calls[2].Sub(start), 2*time.Second)
+	assertEqual(t, calls[3].Sub(start), 2*time.Second)
+}
+
+func TestBackpressureByHostConcurrent(t *testing.T) {
+	var (
+		clock = useManualClock(t)
+		mu    sync.Mutex
+		calls = map[string]time.Time{}
+	)
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		calls[r.URL.Host+r.URL.Path] = clock.Now()
+		mu.Unlock()
+		if r.URL.Path == "/limited" {
+			header := http.Header{}
+			header.Set("Retry-After", "2")
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: header}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+	}), trip.BackpressureByHost())
+
+	start := clock.Now()
+	transport.RoundTrip(httptest.NewRequest("GET", "http://a.example.com/limited", nil))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		transport.RoundTrip(httptest.NewRequest("GET", "http://a.example.com/", nil))
+	}()
+	clock.WaitForTimers(1)
+
+	transport.RoundTrip(httptest.NewRequest("GET", "http://b.example.com/", nil))
+
+	mu.Lock()
+	_, sent := calls["a.example.com/"]
+	assertEqual(t, sent, false)
+	assertEqual(t, calls["b.example.com/"].Sub(start), time.Duration(0))
+	mu.Unlock()
+
+	clock.Advance(2 * time.Second)
+	<-done
+
+	assertEqual(t, calls["a.example.com/"].Sub(start), 2*time.Second)
+}
+
+func TestBackpressureByHostContext(t *testing.T) {
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Retry-After", "60")
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: header}, nil
+	}), trip.BackpressureByHost())
+
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil).WithContext(ctx))
+
+	assertEqual(t, errors.Is(err, context.DeadlineExceeded), true)
+}
@@ -0,0 +1,175 @@
+package trip
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// ErrNoRecording is returned by RecordReplay in replay mode for requests that match
+// none of the recorded requests.
+var ErrNoRecording = errors.New("trip: no recording matches request")
+
+// Mode is the mode of RecordReplay.
+type Mode int
+
+const (
+	// ModeRecord sends requests and records them together with their responses.
+	ModeRecord Mode = iota
+
+	// ModeReplay serves recorded responses without sending requests.
+	ModeReplay
+)
+
+// Recording is a request and its response as stored by RecordReplay.
+type Recording struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request stored in a Recording.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response stored in a Recording.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// RecordReplay records requests and their responses to the JSON file at path, or
+// replays them from it, depending on mode. This allows tests to run deterministically
+// against interactions recorded once from a real server.
+//
+// In record mode, every request is sent and the file is rewritten with all
+// interactions recorded so far. Request headers are recorded as sent, including
+// credentials like the `Authorization` header. In replay mode, requests are answered
+// with the response of the first recording with the same method, URL and body, without
+// sending them. Recordings are used in order, so repeated requests are answered with
+// the responses of their repeated recordings. Requests without a matching recording
+// fail with ErrNoRecording.
+func RecordReplay(path string, mode Mode) TripFunc {
+	return RecordReplayWith(path, mode, matchRecording)
+}
+
+// RecordReplayWith works like RecordReplay, but in replay mode requests are matched
+// with recordings by match. It is called with the request and its buffered body.
+func RecordReplayWith(path string, mode Mode, match func(r *http.Request, body []byte, rec *Recording) bool) TripFunc {
+	if match == nil {
+		panic("trip: record match function is nil")
+	}
+	rr := &recorder{path: path, match: match}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			body, err := bufferedBytes(r)
+			if err != nil {
+				return nil, err
+			}
+
+			if mode == ModeReplay {
+				return rr.replay(r, body)
+			}
+			return rr.record(t, r, body)
+		})
+	}
+}
+
+type recorder struct {
+	path  string
+	match func(r *http.Request, body []byte, rec *Recording) bool
+
+	mu         sync.Mutex
+	loaded     bool
+	recordings []Recording
+	used       []bool
+}
+
+func (rr *recorder) record(t http.RoundTripper, r *http.Request, body []byte) (*http.Response, error) {
+	resp, err := send(t, r)
+	if err != nil {
+		return resp, err
+	}
+
+	var respBody []byte
+	if resp.Body != nil {
+		respBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.recordings = append(rr.recordings, Recording{
+		Request:  RecordedRequest{Method: r.Method, URL: r.URL.String(), Header: r.Header.Clone(), Body: body},
+		Response: RecordedResponse{StatusCode: resp.StatusCode, Header: resp.Header.Clone(), Body: respBody},
+	})
+
+	data, err := json.MarshalIndent(rr.recordings, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(rr.path, data, 0o644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (rr *recorder) replay(r *http.Request, body []byte) (*http.Response, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if !rr.loaded {
+		data, err := os.ReadFile(rr.path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &rr.recordings); err != nil {
+			return nil, fmt.Errorf("trip: invalid recordings in %s: %w", rr.path, err)
+		}
+		rr.used = make([]bool, len(rr.recordings))
+		rr.loaded = true
+	}
+
+	for i := range rr.recordings {
+		rec := &rr.recordings[i]
+		if rr.used[i] || !rr.match(r, body, rec) {
+			continue
+		}
+		rr.used[i] = true
+
+		header := rec.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rec.Response.StatusCode, http.StatusText(rec.Response.StatusCode)),
+			StatusCode:    rec.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(rec.Response.Body)),
+			ContentLength: int64(len(rec.Response.Body)),
+			Request:       r,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoRecording, r.Method, r.URL)
+}
+
+func matchRecording(r *http.Request, body []byte, rec *Recording) bool {
+	return r.Method == rec.Request.Method && r.URL.String() == rec.Request.URL && bytes.Equal(body, rec.Request.Body)
+}
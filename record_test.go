@@ -0,0 +1,83 @@
+package trip_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recordings.json")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Echo", "yes")
+		io.WriteString(w, r.Method+" "+string(body))
+	}))
+
+	post := func(client *http.Client, url, body string) (string, error) {
+		resp, err := client.Post(url, "text/plain", strings.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		assertEqual(t, resp.Header.Get("X-Echo"), "yes")
+		return string(b), nil
+	}
+
+	recorder := &http.Client{Transport: trip.Default(trip.RecordReplay(path, trip.ModeRecord))}
+	got, err := post(recorder, server.URL+"/a", "hello")
+	assertEqual(t, err, nil)
+	assertEqual(t, got, "POST hello")
+	post(recorder, server.URL+"/a", "world")
+
+	url := server.URL
+	server.Close()
+
+	replayer := &http.Client{Transport: trip.Default(trip.RecordReplay(path, trip.ModeReplay))}
+	got, err = post(replayer, url+"/a", "world")
+	assertEqual(t, err, nil)
+	assertEqual(t, got, "POST world")
+
+	got, err = post(replayer, url+"/a", "hello")
+	assertEqual(t, err, nil)
+	assertEqual(t, got, "POST hello")
+
+	_, err = post(replayer, url+"/b", "hello")
+	assertEqual(t, errors.Is(err, trip.ErrNoRecording), true)
+}
+
+func TestRecordReplayWith(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recordings.json")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "recorded")
+	}))
+	recorder := &http.Client{Transport: trip.Default(trip.RecordReplay(path, trip.ModeRecord))}
+	resp, err := recorder.Get(server.URL + "/items?page=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	server.Close()
+
+	matchPath := func(r *http.Request, body []byte, rec *trip.Recording) bool {
+		return strings.Contains(rec.Request.URL, r.URL.Path)
+	}
+	replayer := trip.New(trip.RoundTripperFunc(noop), trip.RecordReplayWith(path, trip.ModeReplay, matchPath))
+
+	resp, err = replayer.RoundTrip(httptest.NewRequest("GET", "http://example.com/items?page=2", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	assertEqual(t, resp.StatusCode, 200)
+	assertEqual(t, string(body), "recorded")
+}
@@ -0,0 +1,140 @@
+package trip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrTooManyRedirects is returned by FollowRedirects when a request is redirected
+// more often than allowed.
+var ErrTooManyRedirects = errors.New("trip: stopped after too many redirects")
+
+// ErrRedirectLoop is returned by FollowRedirects when a request is redirected to a URL
+// it was already sent to.
+var ErrRedirectLoop = errors.New("trip: stopped after redirect loop")
+
+// FollowRedirects follows up to max redirects of a request, so that subsequent
+// trip functions see the redirected requests. Bodies of intermediate responses
+// are drained and closed.
+//
+// Like the http.Client does, 301, 302 and 303 redirects are followed with a GET
+// request without body, while 307 and 308 redirects keep the method and body if
+// the body can be recreated through GetBody. Authorization and Cookie headers are
+// removed when redirected to a different host. Trip functions placed before
+// FollowRedirects that add them again can be guarded by StripAuthOnRedirect.
+//
+// Redirects leading back to a URL already requested with the same method fail with
+// ErrRedirectLoop, even if max isn't reached yet.
+func FollowRedirects(max int) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			origin := *r.URL
+			ctx := context.WithValue(r.Context(), redirectOriginKey{}, &origin)
+			visited := map[string]bool{r.Method + " " + r.URL.String(): true}
+			resp, err := send(t, r)
+
+			for redirects := 0; err == nil && isRedirect(resp); redirects++ {
+				next, ok := redirectRequest(r, resp)
+				if !ok {
+					break
+				}
+				drain(resp, defaultDrainLimit)
+				key := next.Method + " " + next.URL.String()
+				if visited[key] {
+					return nil, ErrRedirectLoop
+				}
+				if redirects == max {
+					return nil, ErrTooManyRedirects
+				}
+				visited[key] = true
+
+				r = next.WithContext(ctx)
+				resp, err = send(t, r)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+type redirectOriginKey struct{}
+
+// StripAuthOnRedirect removes the `Authorization` and `Cookie` headers, as well as the
+// given sensitive headers, from requests that FollowRedirects redirected to a different
+// origin than the one of the original request. An origin consists of the scheme, host
+// and port. StripAuthOnRedirect must be placed before FollowRedirects and before any
+// trip function setting these headers, like BearerToken, so that they don't leak to
+// other hosts.
+func StripAuthOnRedirect(headers ...string) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			origin, ok := r.Context().Value(redirectOriginKey{}).(*url.URL)
+			if ok && !sameOrigin(origin, r.URL) {
+				r.Header.Del("Authorization")
+				r.Header.Del("Cookie")
+				for _, header := range headers {
+					r.Header.Del(header)
+				}
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(stripDefaultPort(a.Scheme, a.Host), stripDefaultPort(b.Scheme, b.Host))
+}
+
+func isRedirect(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return resp.Header.Get("Location") != ""
+	}
+	return false
+}
+
+// redirectRequest builds the request following the redirect resp. It reports
+// false if the redirect can't be followed.
+func redirectRequest(r *http.Request, resp *http.Response) (*http.Request, bool) {
+	loc, err := r.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return nil, false
+	}
+
+	next := r.Clone(r.Context())
+	next.URL = loc
+	next.Host = ""
+
+	switch resp.StatusCode {
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if hasBody(r) {
+			if r.GetBody == nil {
+				return nil, false
+			}
+			if next.Body, err = r.GetBody(); err != nil {
+				return nil, false
+			}
+		}
+	default:
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.Method = http.MethodGet
+		}
+		next.Body = nil
+		next.GetBody = nil
+		next.ContentLength = 0
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	}
+
+	if loc.Host != r.URL.Host {
+		next.Header.Del("Authorization")
+		next.Header.Del("Cookie")
+	}
+
+	return next, true
+}
@@ -0,0 +1,150 @@
+package trip_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestFollowRedirects(t *testing.T) {
+	var paths []string
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/a":
+			return redirect(http.StatusSeeOther, "/b"), nil
+		case "/b":
+			return redirect(http.StatusFound, "http://example.com/c"), nil
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("done"))}, nil
+	}), trip.FollowRedirects(5))
+
+	req := httptest.NewRequest("POST", "http://example.com/a", strings.NewReader("body"))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, resp.StatusCode, 200)
+	assertEqual(t, strings.Join(paths, ","), "POST /a,GET /b,GET /c")
+}
+
+func TestFollowRedirectsKeepsBody(t *testing.T) {
+	var bodies []string
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.Method+" "+string(body))
+		if r.URL.Path == "/a" {
+			return redirect(http.StatusTemporaryRedirect, "/b"), nil
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.FollowRedirects(5))
+
+	req, _ := http.NewRequest("POST", "http://example.com/a", strings.NewReader("body"))
+	transport.RoundTrip(req)
+
+	assertEqual(t, strings.Join(bodies, ","), "POST body,POST body")
+}
+
+func TestFollowRedirectsCrossHost(t *testing.T) {
+	var auth []string
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		switch r.URL.Host + r.URL.Path {
+		case "api.example.com/":
+			return redirect(http.StatusFound, "/login"), nil
+		case "api.example.com/login":
+			return redirect(http.StatusFound, "http://example.com/"), nil
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.FollowRedirects(5))
+
+	req := httptest.NewRequest("GET", "http://api.example.com/", nil)
+	req.Header.Set("Authorization", "Bearer abc123")
+	transport.RoundTrip(req)
+
+	assertEqual(t, len(auth), 3)
+	assertEqual(t, auth[0], "Bearer abc123")
+	assertEqual(t, auth[1], "Bearer abc123")
+	assertEqual(t, auth[2], "")
+}
+
+func TestStripAuthOnRedirect(t *testing.T) {
+	type sent struct{ host, auth, key string }
+	var requests []sent
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, sent{r.URL.Host, r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")})
+		switch r.URL.Host + r.URL.Path {
+		case "api.example.com/":
+			return redirect(http.StatusFound, "/login"), nil
+		case "api.example.com/login":
+			return redirect(http.StatusFound, "http://cdn.example.com/"), nil
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}),
+		trip.StripAuthOnRedirect("X-Api-Key"),
+		trip.BearerToken("abc123"),
+		trip.Header("X-Api-Key", "secret"),
+		trip.FollowRedirects(5),
+	)
+
+	transport.RoundTrip(httptest.NewRequest("GET", "http://api.example.com/", nil))
+
+	assertEqual(t, len(requests), 3)
+	assertEqual(t, requests[0], sent{"api.example.com", "Bearer abc123", "secret"})
+	assertEqual(t, requests[1], sent{"api.example.com", "Bearer abc123", "secret"})
+	assertEqual(t, requests[2], sent{"cdn.example.com", "", ""})
+}
+
+func TestFollowRedirectsMax(t *testing.T) {
+	calls := 0
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return redirect(http.StatusFound, "/next"+strconv.Itoa(calls)), nil
+	}), trip.FollowRedirects(2))
+
+	_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+
+	assertEqual(t, calls, 3)
+	assertEqual(t, errors.Is(err, trip.ErrTooManyRedirects), true)
+}
+
+func TestFollowRedirectsLoop(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/a", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport:     trip.Default(trip.FollowRedirects(10)),
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	_, err := client.Get(server.URL + "/a")
+
+	assertEqual(t, errors.Is(err, trip.ErrRedirectLoop), true)
+	assertEqual(t, strings.Join(paths, ","), "/a,/b")
+}
+
+func redirect(code int, location string) *http.Response {
+	header := http.Header{}
+	header.Set("Location", location)
+	return &http.Response{StatusCode: code, Header: header, Body: io.NopCloser(strings.NewReader(""))}
+}
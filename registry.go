@@ -0,0 +1,31 @@
+package trip
+
+import (
+	"net/http"
+	"sync"
+)
+
+var (
+	transportsMu sync.RWMutex
+	transports   = map[string]http.RoundTripper{}
+)
+
+// Register makes a transport available under the given name, so that it can be looked
+// up with Get where needed instead of being passed around. Registering a name again
+// replaces the previous transport. Register panics if rt is nil.
+func Register(name string, rt http.RoundTripper) {
+	if rt == nil {
+		panic("trip: registered transport is nil")
+	}
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	transports[name] = rt
+}
+
+// Get returns the transport registered under name with Register.
+func Get(name string) (http.RoundTripper, bool) {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	rt, ok := transports[name]
+	return rt, ok
+}
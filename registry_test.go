@@ -0,0 +1,51 @@
+package trip_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestRegister(t *testing.T) {
+	t.Cleanup(func() { trip.UnregisterTransport("registry-test") })
+
+	_, ok := trip.Get("registry-test")
+	assertEqual(t, ok, false)
+
+	first := &http.Transport{}
+	trip.Register("registry-test", first)
+	rt, ok := trip.Get("registry-test")
+	assertEqual(t, ok, true)
+	assertEqual(t, rt, http.RoundTripper(first))
+
+	second := &http.Transport{}
+	trip.Register("registry-test", second)
+	rt, _ = trip.Get("registry-test")
+	assertEqual(t, rt, http.RoundTripper(second))
+}
+
+func TestRegisterConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("registry-test-%d", i)
+		t.Cleanup(func() { trip.UnregisterTransport(name) })
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			trip.Register(name, http.DefaultTransport)
+		}()
+		go func() {
+			defer wg.Done()
+			trip.Get(name)
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+		_, ok := trip.Get(fmt.Sprintf("registry-test-%d", i))
+		assertEqual(t, ok, true)
+	}
+}
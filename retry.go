@@ -0,0 +1,506 @@
+package trip
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRetriesExhausted is returned by retries configured with RetryExhaustedError
+// when all attempts of a request have failed.
+var ErrRetriesExhausted = errors.New("trip: giving up")
+
+// RetryError is returned by retries configured with RetryExhaustedError when the last
+// attempt of a request failed with a retryable status code. It matches
+// ErrRetriesExhausted with errors.Is.
+type RetryError struct {
+	Attempts   int
+	StatusCode int
+	Status     string
+	Header     http.Header
+}
+
+// Error satisfies the error interface.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v after %d attempts, last status %s", ErrRetriesExhausted, e.Attempts, e.Status)
+}
+
+// Is reports whether target is ErrRetriesExhausted.
+func (e *RetryError) Is(target error) bool {
+	return target == ErrRetriesExhausted
+}
+
+// attemptKey is the context key under which Retry stores the current attempt number.
+type attemptKey struct{}
+
+// RetryableStatusCodes contains common HTTP status codes
+// that are considered temporary and can be retried.
+var RetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooEarly,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+var retryableStatus = statusSet(RetryableStatusCodes)
+
+// IsRetryableStatus reports whether code is one of the status codes initially contained
+// in RetryableStatusCodes.
+func IsRetryableStatus(code int) bool {
+	return retryableStatus[code]
+}
+
+func statusSet(codes []int) map[int]bool {
+	set := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+// Retry retries a failed HTTP request a given number of times and applies a fixed delay
+// inbetween calls. Optionally a list of HTTP status codes can be provided that are
+// considered as failure case.
+// This can be used in combination with RetryableStatusCodes.
+//
+// Errors that won't resolve by trying again, like DNS lookups of unknown hosts or failed
+// certificate verifications, are returned immediately without further attempts.
+//
+// Request bodies are never buffered. If the request has a body and GetBody is set, as
+// done by http.NewRequest for in-memory bodies, a fresh body is obtained from GetBody
+// for every further attempt, so large streaming bodies can be retried as long as they
+// can be recreated. Requests with a body but without GetBody can't be rewound once sent
+// and are therefore attempted only once.
+//
+// Waiting between attempts is aborted with the context error when the request
+// context is done.
+func Retry(attempts int, delay time.Duration, statusCodes ...int) TripFunc {
+	return RetryWith(attempts, ConstantBackoff(delay), statusCodes...)
+}
+
+// RetryWith works like Retry, but the delay inbetween calls is determined by the given
+// backoff strategy.
+func RetryWith(attempts int, b Backoff, statusCodes ...int) TripFunc {
+	return RetryWithOptions(attempts, b, RetryOn(statusCodes...))
+}
+
+// RetryIdempotent works like Retry, but only retries requests that are safe to repeat.
+// These are requests with an idempotent method (GET, HEAD, OPTIONS, TRACE, PUT, DELETE)
+// and requests carrying an `Idempotency-Key` header. All other requests are sent once.
+// If used in conjunction with IdempotencyKey, IdempotencyKey should be applied after
+// RetryIdempotent.
+func RetryIdempotent(attempts int, delay time.Duration, statusCodes ...int) TripFunc {
+	return RetryWithOptions(attempts, ConstantBackoff(delay), RetryOn(statusCodes...), retryIf(isIdempotent))
+}
+
+// RetryWhen works like Retry, but only retries requests for which pred returns true,
+// e.g. requests carrying a feature flag header. All other requests are sent once.
+func RetryWhen(pred func(*http.Request) bool, attempts int, delay time.Duration, statusCodes ...int) TripFunc {
+	if pred == nil {
+		panic("trip: retry predicate is nil")
+	}
+	return RetryWithOptions(attempts, ConstantBackoff(delay), RetryOn(statusCodes...), retryIf(pred))
+}
+
+// RetrySmart retries failed requests with an exponentially growing delay between base
+// and max, randomized as by FullJitterBackoff. If a failed response carries a
+// `Retry-After` header, at least the requested delay is waited. Waiting is aborted
+// when the request context is done. Request bodies that can't be recreated through
+// GetBody are buffered in memory, so that every attempt sends the full body.
+func RetrySmart(attempts int, base, max time.Duration, statusCodes ...int) TripFunc {
+	return RetryWithOptions(attempts, FullJitterBackoff(base, max), RetryOn(statusCodes...), retryAfter(), retryBuffered())
+}
+
+// RetryByStatus works like Retry, but the delay before the next attempt depends on the
+// status code of the failed response, as given by delays. Network errors and status
+// codes without a delay wait defaultDelay. Responses with a status code of
+// RetryableStatusCodes or delays are retried. If a failed response carries a
+// `Retry-After` header, at least the requested delay is waited.
+func RetryByStatus(attempts int, delays map[int]time.Duration, defaultDelay time.Duration) TripFunc {
+	codes := append([]int(nil), RetryableStatusCodes...)
+	statusDelays := make(map[int]time.Duration, len(delays))
+	for code, d := range delays {
+		codes = append(codes, code)
+		statusDelays[code] = d
+	}
+	return RetryWithOptions(attempts, ConstantBackoff(defaultDelay), RetryOn(codes...), retryDelays(statusDelays), retryAfter())
+}
+
+// RetryOption configures the retry behaviour of RetryWithOptions.
+type RetryOption func(*retryConfig)
+
+// RetryOn sets the HTTP status codes that are considered as failure case.
+func RetryOn(statusCodes ...int) RetryOption {
+	return func(c *retryConfig) {
+		c.statusCodes = statusSet(statusCodes)
+	}
+}
+
+// RetryDrainLimit sets the maximum number of bytes read from the body of a failed
+// response before it is closed and the request is retried. Reading the body allows
+// the connection to be reused, while a limit avoids reading large error pages.
+// A limit of 0 closes the body without reading it, and a negative limit reads the
+// whole body. Defaults to 16 KiB.
+func RetryDrainLimit(n int64) RetryOption {
+	return func(c *retryConfig) {
+		c.drainLimit = n
+	}
+}
+
+// RetryExhaustedError makes a retry return an error wrapping ErrRetriesExhausted when
+// all attempts failed. If the last attempt failed with a retryable status code, its
+// response body is drained and closed, and a *RetryError carrying the status and
+// header is returned instead of the response. If it failed with an error, both errors
+// are joined.
+func RetryExhaustedError() RetryOption {
+	return func(c *retryConfig) {
+		c.exhaustedError = true
+	}
+}
+
+// RetryWarn sets a log function that is called when a request isn't retried because
+// its body can't be replayed, i.e. it has a body but no GetBody.
+func RetryWarn(f func(format string, v ...any)) RetryOption {
+	return func(c *retryConfig) {
+		c.warn = f
+	}
+}
+
+// retryAfter makes a retry wait at least as long as requested by the `Retry-After`
+// header of a failed response.
+func retryAfter() RetryOption {
+	return func(c *retryConfig) {
+		c.retryAfter = true
+	}
+}
+
+// retryBuffered makes a retry buffer request bodies that can't be recreated through
+// GetBody in memory, instead of sending such requests only once.
+func retryBuffered() RetryOption {
+	return func(c *retryConfig) {
+		c.bufferBody = true
+	}
+}
+
+// retryDelays makes a retry wait the delay given for the status code of a failed
+// response instead of the one of the backoff.
+func retryDelays(delays map[int]time.Duration) RetryOption {
+	return func(c *retryConfig) {
+		c.statusDelays = delays
+	}
+}
+
+func retryIf(shouldRetry func(*http.Request) bool) RetryOption {
+	return func(c *retryConfig) {
+		c.shouldRetry = shouldRetry
+	}
+}
+
+// RetryWithOptions works like RetryWith, but its behaviour is customized with options.
+func RetryWithOptions(attempts int, b Backoff, opts ...RetryOption) TripFunc {
+	return NewRetryPolicy(attempts, b, opts...).TripFunc()
+}
+
+// RetryPolicy holds the retry behaviour of RetryWithOptions, so that it can be used
+// for operations other than sending a request through a transport.
+type RetryPolicy struct {
+	c retryConfig
+}
+
+// NewRetryPolicy creates a RetryPolicy making up to the given number of attempts with
+// delays determined by b. Its behaviour is customized with options, like the one of
+// RetryWithOptions.
+func NewRetryPolicy(attempts int, b Backoff, opts ...RetryOption) *RetryPolicy {
+	if b == nil {
+		panic("trip: backoff is nil")
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	p := &RetryPolicy{c: retryConfig{attempts: attempts, backoff: b, drainLimit: defaultDrainLimit}}
+	for _, opt := range opts {
+		opt(&p.c)
+	}
+	return p
+}
+
+// Execute calls fn until it returns a response with a status code that isn't
+// considered as failure case, or an error that won't resolve by trying again, or all
+// attempts are used up. Bodies of discarded responses are drained and closed. Waiting
+// between attempts is aborted with the context error when ctx is done.
+func (p *RetryPolicy) Execute(ctx context.Context, fn func() (*http.Response, error)) (*http.Response, error) {
+	return p.c.execute(ctx, p.c.attempts, p.c.backoff, func(int) (*http.Response, error) {
+		return fn()
+	})
+}
+
+// TripFunc returns a trip function retrying failed requests according to p.
+func (p *RetryPolicy) TripFunc() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return p.c.roundTrip(t, r)
+		})
+	}
+}
+
+// AttemptFromContext returns the number of the current attempt, starting at 1,
+// stored in the request context by Retry. It reports false if the request
+// was not sent through Retry.
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	attempt, ok := ctx.Value(attemptKey{}).(int)
+	return attempt, ok
+}
+
+type attemptCounterKey struct{}
+
+// attemptCounter counts the attempts of a request. It links to the counter of an
+// enclosing trip function, so that several trip functions placed after Retry can
+// count the attempts of the same request.
+type attemptCounter struct {
+	n      atomic.Int32
+	parent *attemptCounter
+}
+
+// add increments the counter and all counters it is linked to.
+func (c *attemptCounter) add() {
+	for ; c != nil; c = c.parent {
+		c.n.Add(1)
+	}
+}
+
+// withAttemptCounter returns a copy of ctx carrying a counter that is incremented
+// by Retry for every attempt. It lets trip functions placed after Retry learn how
+// many attempts a request took.
+func withAttemptCounter(ctx context.Context) (context.Context, *attemptCounter) {
+	parent, _ := ctx.Value(attemptCounterKey{}).(*attemptCounter)
+	counter := &attemptCounter{parent: parent}
+	return context.WithValue(ctx, attemptCounterKey{}, counter), counter
+}
+
+// retries returns the number of retries of a request, given the context of the request
+// and the attempt counter created for it by withAttemptCounter.
+func retries(ctx context.Context, counter *attemptCounter) int {
+	n := int(counter.n.Load())
+	if attempt, ok := AttemptFromContext(ctx); ok && attempt > n {
+		n = attempt
+	}
+	if n < 1 {
+		return 0
+	}
+	return n - 1
+}
+
+type retryOverrideKey struct{}
+
+type retryOverride struct {
+	attempts int
+	delay    time.Duration
+}
+
+// WithRetry returns a copy of ctx that makes retries use the given number of attempts
+// and a fixed delay for requests carrying it, instead of their configured values.
+func WithRetry(ctx context.Context, attempts int, delay time.Duration) context.Context {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return context.WithValue(ctx, retryOverrideKey{}, retryOverride{attempts: attempts, delay: delay})
+}
+
+type retryConfig struct {
+	attempts     int
+	backoff      Backoff
+	statusCodes  map[int]bool
+	statusDelays map[int]time.Duration
+	shouldRetry  func(*http.Request) bool
+	drainLimit   int64
+
+	exhaustedError bool
+	retryAfter     bool
+	bufferBody     bool
+	warn           func(format string, v ...any)
+}
+
+func (c *retryConfig) retryable(statusCode int) bool {
+	return c.statusCodes[statusCode]
+}
+
+func (c *retryConfig) roundTrip(t http.RoundTripper, r *http.Request) (*http.Response, error) {
+	n, backoff := c.attempts, c.backoff
+	if o, ok := r.Context().Value(retryOverrideKey{}).(retryOverride); ok {
+		n, backoff = o.attempts, ConstantBackoff(o.delay)
+	}
+	if c.shouldRetry != nil && !c.shouldRetry(r) || isDisabled(r.Context(), "retry") {
+		n = 1
+	}
+	if c.bufferBody && n > 1 && hasBody(r) && r.GetBody == nil {
+		if err := ensureReplayable(r, -1); err != nil {
+			return nil, err
+		}
+	}
+	if n > 1 && hasBody(r) && r.GetBody == nil {
+		if c.warn != nil {
+			c.warn("trip: not retrying %s %s: request body can't be replayed", r.Method, r.URL.String())
+		}
+		n = 1
+	}
+
+	return c.execute(r.Context(), n, backoff, func(attempt int) (*http.Response, error) {
+		req := r.WithContext(context.WithValue(r.Context(), attemptKey{}, attempt))
+		if attempt > 1 && hasBody(r) {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, permanentError{err}
+			}
+			req.Body = body
+		}
+		return send(t, req)
+	})
+}
+
+// execute calls fn with the attempt number, starting at 1, up to n times, until it
+// succeeds or fails permanently.
+func (c *retryConfig) execute(ctx context.Context, n int, backoff Backoff, fn func(attempt int) (*http.Response, error)) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+
+	counter, _ := ctx.Value(attemptCounterKey{}).(*attemptCounter)
+	budget, _ := ctx.Value(retryBudgetKey{}).(*retryBudget)
+
+	for i := 0; i < n; i++ {
+		counter.add()
+		resp, err = fn(i + 1)
+		if resp == nil && err == nil {
+			err = ErrNilResponse
+		}
+		if err != nil && !retryableError(ctx, err) || err == nil && !c.retryable(resp.StatusCode) {
+			if p, ok := err.(permanentError); ok {
+				err = p.err
+			}
+			return resp, err
+		}
+		if i < n-1 && budget != nil && !budget.allowRetry() {
+			n = i + 1
+		}
+		if i == n-1 {
+			break
+		}
+		delay := backoff.Next(i + 1)
+		if err == nil {
+			if d, ok := c.statusDelays[resp.StatusCode]; ok {
+				delay = d
+			}
+		}
+		if c.retryAfter && resp != nil {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && d > delay {
+				delay = d
+			}
+		}
+		drain(resp, c.drainLimit)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.exhaustedError && n > 1 {
+		drain(resp, c.drainLimit)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("%w after %d attempts", ErrRetriesExhausted, n), err)
+		}
+		return nil, &RetryError{Attempts: n, StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header}
+	}
+	return resp, err
+}
+
+// permanentError marks an error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// retryableError reports whether an operation that failed with err may succeed
+// when tried again. Errors of unknown kind are considered retryable.
+func retryableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if _, ok := err.(permanentError); ok {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+
+	var (
+		certErr      *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	if errors.As(err, &certErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return false
+	}
+
+	return true
+}
+
+// parseRetryAfter returns the delay requested by a `Retry-After` header value, which is
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		d := date.Sub(clk.Now())
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// defaultDrainLimit is the maximum number of bytes read from discarded response bodies.
+const defaultDrainLimit = 16 << 10
+
+// drain reads up to limit bytes of the response body and closes it. A negative limit
+// reads the whole body.
+func drain(resp *http.Response, limit int64) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	if limit < 0 {
+		io.Copy(io.Discard, resp.Body)
+	} else if limit > 0 {
+		io.CopyN(io.Discard, resp.Body, limit)
+	}
+	resp.Body.Close()
+}
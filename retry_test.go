@@ -0,0 +1,575 @@
+package trip_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestRetryIdempotent(t *testing.T) {
+	var (
+		attempts = 3
+		delay    = time.Millisecond
+	)
+
+	tests := []struct {
+		method   string
+		key      string
+		expected int
+	}{
+		{method: "GET", expected: attempts},
+		{method: "PUT", expected: attempts},
+		{method: "POST", expected: 1},
+		{method: "POST", key: "abc123", expected: attempts},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return nil, errors.New("network error")
+		}), trip.RetryIdempotent(attempts, delay))
+
+		req := httptest.NewRequest(tt.method, "http://example.com/foo", nil)
+		if tt.key != "" {
+			req.Header.Set("Idempotency-Key", tt.key)
+		}
+		transport.RoundTrip(req)
+
+		assertEqual(t, calls, tt.expected)
+	}
+}
+
+func TestRetryWhen(t *testing.T) {
+	pred := func(r *http.Request) bool { return r.Header.Get("X-Retry") == "on" }
+
+	tests := []struct {
+		flag     string
+		expected int
+	}{
+		{flag: "on", expected: 3},
+		{flag: "", expected: 1},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+		}), trip.RetryWhen(pred, 3, time.Millisecond, http.StatusServiceUnavailable))
+
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if tt.flag != "" {
+			req.Header.Set("X-Retry", tt.flag)
+		}
+		transport.RoundTrip(req)
+
+		assertEqual(t, calls, tt.expected)
+	}
+}
+
+func TestRetryErrorClassification(t *testing.T) {
+	var (
+		attempts = 3
+		delay    = time.Millisecond
+	)
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "unknown", err: errors.New("network error"), expected: attempts},
+		{name: "timeout", err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, expected: attempts},
+		{name: "refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, expected: attempts},
+		{name: "reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, expected: attempts},
+		{name: "dns temporary", err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}, expected: attempts},
+		{name: "dns not found", err: &net.DNSError{Err: "no such host", IsNotFound: true}, expected: 1},
+		{name: "certificate", err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, expected: 1},
+		{name: "hostname", err: x509.HostnameError{Host: "example.com"}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		roundTrip(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return nil, tt.err
+		}, trip.Retry(attempts, delay))
+
+		if calls != tt.expected {
+			t.Errorf("%s: got: %v calls, expected: %v", tt.name, calls, tt.expected)
+		}
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		cancel()
+		return nil, r.Context().Err()
+	}), trip.Retry(3, time.Millisecond))
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil).WithContext(ctx)
+	transport.RoundTrip(req)
+
+	assertEqual(t, calls, 1)
+}
+
+func TestRetryWith(t *testing.T) {
+	var (
+		clock    = useFakeClock(t)
+		calls    []time.Time
+		attempts = 3
+		backoff  = trip.BackoffFunc(func(attempt int) time.Duration {
+			return time.Duration(attempt) * 2 * time.Millisecond
+		})
+	)
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		calls = append(calls, clock.Now())
+		return nil, errors.New("network error")
+	}, trip.RetryWith(attempts, backoff))
+
+	assertEqual(t, len(calls), attempts)
+	assertEqual(t, calls[1].Sub(calls[0]), 2*time.Millisecond)
+	assertEqual(t, calls[2].Sub(calls[1]), 4*time.Millisecond)
+}
+
+func TestAttemptFromContext(t *testing.T) {
+	var attempts []int
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		attempt, ok := trip.AttemptFromContext(r.Context())
+		assertEqual(t, ok, true)
+		attempts = append(attempts, attempt)
+		return nil, errors.New("network error")
+	}, trip.Retry(3, time.Millisecond))
+
+	assertEqual(t, len(attempts), 3)
+	for i, attempt := range attempts {
+		assertEqual(t, attempt, i+1)
+	}
+}
+
+func TestAttemptFromContextWithoutRetry(t *testing.T) {
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		_, ok := trip.AttemptFromContext(r.Context())
+		assertEqual(t, ok, false)
+		return nil, nil
+	})
+}
+
+func TestRetryDrainLimit(t *testing.T) {
+	tests := []struct {
+		opts     []trip.RetryOption
+		expected int64
+	}{
+		{expected: 16 << 10},
+		{opts: []trip.RetryOption{trip.RetryDrainLimit(1 << 10)}, expected: 1 << 10},
+		{opts: []trip.RetryOption{trip.RetryDrainLimit(0)}, expected: 0},
+		{opts: []trip.RetryOption{trip.RetryDrainLimit(-1)}, expected: 1 << 20},
+	}
+
+	for _, tt := range tests {
+		var bodies []*countingBody
+
+		opts := append([]trip.RetryOption{trip.RetryOn(http.StatusBadGateway)}, tt.opts...)
+		roundTrip(func(r *http.Request) (*http.Response, error) {
+			body := &countingBody{Reader: strings.NewReader(strings.Repeat("x", 1<<20))}
+			bodies = append(bodies, body)
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: body}, nil
+		}, trip.RetryWithOptions(2, trip.ConstantBackoff(time.Millisecond), opts...))
+
+		assertEqual(t, len(bodies), 2)
+		assertEqual(t, bodies[0].read, tt.expected)
+		assertEqual(t, bodies[0].closed, true)
+		assertEqual(t, bodies[1].read, int64(0))
+		assertEqual(t, bodies[1].closed, false)
+	}
+}
+
+type countingBody struct {
+	io.Reader
+	read   int64
+	closed bool
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRetryExhaustedError(t *testing.T) {
+	var bodies []*countingBody
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body := &countingBody{Reader: strings.NewReader("unavailable")}
+		bodies = append(bodies, body)
+		return &http.Response{
+			Status:     "502 Bad Gateway",
+			StatusCode: http.StatusBadGateway,
+			Header:     http.Header{"X-Request-Id": {"abc"}},
+			Body:       body,
+		}, nil
+	}), trip.RetryWithOptions(3, trip.ConstantBackoff(time.Millisecond), trip.RetryOn(http.StatusBadGateway), trip.RetryExhaustedError()))
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get("http://example.com/")
+	assertEqual(t, resp, nil)
+	assertEqual(t, errors.Is(err, trip.ErrRetriesExhausted), true)
+
+	var retryErr *trip.RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("got: %v, expected: *trip.RetryError", err)
+	}
+	assertEqual(t, retryErr.Error(), "trip: giving up after 3 attempts, last status 502 Bad Gateway")
+	assertEqual(t, retryErr.StatusCode, http.StatusBadGateway)
+	assertEqual(t, retryErr.Header.Get("X-Request-Id"), "abc")
+
+	assertEqual(t, len(bodies), 3)
+	for _, body := range bodies {
+		assertEqual(t, body.closed, true)
+	}
+}
+
+func TestRetryExhaustedErrorJoined(t *testing.T) {
+	errNetwork := errors.New("network error")
+
+	_, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errNetwork
+	}, trip.RetryWithOptions(3, trip.ConstantBackoff(time.Millisecond), trip.RetryExhaustedError()))
+
+	assertEqual(t, errors.Is(err, trip.ErrRetriesExhausted), true)
+	assertEqual(t, errors.Is(err, errNetwork), true)
+}
+
+func TestRetryExhaustedErrorSuccess(t *testing.T) {
+	calls := 0
+
+	_, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		calls++
+		if calls < 2 {
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}, trip.RetryWithOptions(3, trip.ConstantBackoff(time.Millisecond), trip.RetryOn(http.StatusBadGateway), trip.RetryExhaustedError()))
+
+	assertEqual(t, calls, 2)
+	if err != nil {
+		t.Errorf("got: %v, expected no error", err)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		ctx      context.Context
+		expected int
+	}{
+		{ctx: context.Background(), expected: 3},
+		{ctx: trip.WithRetry(context.Background(), 1, time.Millisecond), expected: 1},
+		{ctx: trip.WithRetry(context.Background(), 5, time.Millisecond), expected: 5},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return nil, errors.New("network error")
+		}), trip.Retry(3, time.Millisecond))
+
+		transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil).WithContext(tt.ctx))
+		assertEqual(t, calls, tt.expected)
+	}
+}
+
+func TestRetryDisabled(t *testing.T) {
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, errors.New("network error")
+	}), trip.Retry(3, time.Millisecond))
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	transport.RoundTrip(req.WithContext(trip.Disable(req.Context(), "retry")))
+
+	assertEqual(t, calls, 1)
+}
+
+func TestRetryNilResponse(t *testing.T) {
+	calls := 0
+
+	resp, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, nil
+	}, trip.Retry(3, time.Millisecond, trip.RetryableStatusCodes...))
+
+	assertEqual(t, calls, 3)
+	assertEqual(t, resp, nil)
+	assertEqual(t, err, trip.ErrNilResponse)
+}
+
+func TestRetryGetBody(t *testing.T) {
+	useFakeClock(t)
+
+	var bodies []string
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.Retry(3, time.Second, trip.RetryableStatusCodes...))
+
+	req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("payload"))
+	transport.RoundTrip(req)
+
+	assertEqual(t, len(bodies), 3)
+	for _, body := range bodies {
+		assertEqual(t, body, "payload")
+	}
+}
+
+func TestRetryUnrewindableBody(t *testing.T) {
+	useFakeClock(t)
+
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		io.CopyN(io.Discard, r.Body, 3)
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.Retry(3, time.Second, trip.RetryableStatusCodes...))
+
+	req, _ := http.NewRequest("POST", "http://example.com/", io.MultiReader(strings.NewReader("payload")))
+	assertEqual(t, req.GetBody == nil, true)
+
+	resp, err := transport.RoundTrip(req)
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.StatusCode, http.StatusBadGateway)
+	assertEqual(t, calls, 1)
+}
+
+func TestRetryWarnUnreplayableBody(t *testing.T) {
+	var warnings []string
+	warn := func(format string, v ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, v...))
+	}
+
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, errors.New("network error")
+	}), trip.RetryWithOptions(3, trip.ConstantBackoff(0), trip.RetryWarn(warn)))
+
+	req, _ := http.NewRequest("PUT", "http://example.com/upload", io.MultiReader(strings.NewReader("payload")))
+	transport.RoundTrip(req)
+
+	assertEqual(t, calls, 1)
+	assertEqual(t, len(warnings), 1)
+	assertEqual(t, warnings[0], "trip: not retrying PUT http://example.com/upload: request body can't be replayed")
+}
+
+func TestRetrySmartJitter(t *testing.T) {
+	clock := useFakeClock(t)
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}, trip.RetrySmart(6, time.Second, 8*time.Second, trip.RetryableStatusCodes...))
+
+	sleeps := clock.Sleeps()
+	assertEqual(t, len(sleeps), 5)
+	for i, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		if sleeps[i] < 0 || sleeps[i] > max {
+			t.Errorf("got sleep %v for attempt %d, expected at most %v", sleeps[i], i+1, max)
+		}
+	}
+}
+
+func TestRetrySmartRetryAfter(t *testing.T) {
+	clock := useFakeClock(t)
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+		resp.Header.Set("Retry-After", "30")
+		return resp, nil
+	}, trip.RetrySmart(3, time.Millisecond, time.Second, trip.RetryableStatusCodes...))
+
+	sleeps := clock.Sleeps()
+	assertEqual(t, len(sleeps), 2)
+	assertEqual(t, sleeps[0], 30*time.Second)
+	assertEqual(t, sleeps[1], 30*time.Second)
+}
+
+func TestRetryByStatus(t *testing.T) {
+	clock := useFakeClock(t)
+
+	statuses := []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK}
+	calls := 0
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		status := statuses[calls]
+		calls++
+		resp := &http.Response{StatusCode: status, Header: http.Header{}, Body: http.NoBody}
+		if status == http.StatusTooManyRequests {
+			resp.Header.Set("Retry-After", "30")
+		}
+		return resp, nil
+	}, trip.RetryByStatus(5, map[int]time.Duration{
+		http.StatusServiceUnavailable: 5 * time.Second,
+		http.StatusTooManyRequests:    time.Second,
+	}, 100*time.Millisecond))
+
+	sleeps := clock.Sleeps()
+	assertEqual(t, calls, 4)
+	assertEqual(t, len(sleeps), 3)
+	assertEqual(t, sleeps[0], 5*time.Second)
+	assertEqual(t, sleeps[1], 100*time.Millisecond)
+	assertEqual(t, sleeps[2], 30*time.Second)
+}
+
+func TestRetryByStatusNetworkError(t *testing.T) {
+	clock := useFakeClock(t)
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("network error")
+	}, trip.RetryByStatus(2, map[int]time.Duration{http.StatusServiceUnavailable: 5 * time.Second}, 100*time.Millisecond))
+
+	assertEqual(t, clock.Sleeps()[0], 100*time.Millisecond)
+}
+
+func TestRetrySmartBody(t *testing.T) {
+	useFakeClock(t)
+
+	var bodies []string
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.RetrySmart(3, time.Second, time.Minute, trip.RetryableStatusCodes...))
+
+	req, _ := http.NewRequest("POST", "http://example.com/", io.MultiReader(strings.NewReader("payload")))
+	transport.RoundTrip(req)
+
+	assertEqual(t, len(bodies), 3)
+	for _, body := range bodies {
+		assertEqual(t, body, "payload")
+	}
+}
+
+func TestRetrySmartContextCanceled(t *testing.T) {
+	useFakeClock(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		cancel()
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.RetrySmart(3, time.Second, time.Minute, trip.RetryableStatusCodes...))
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com/", nil)
+	_, err := transport.RoundTrip(req)
+
+	assertEqual(t, errors.Is(err, context.Canceled), true)
+	assertEqual(t, calls, 1)
+}
+
+func TestRetryPolicyExecute(t *testing.T) {
+	clock := useFakeClock(t)
+
+	policy := trip.NewRetryPolicy(4, trip.ExponentialBackoff(time.Second, time.Minute), trip.RetryOn(http.StatusServiceUnavailable))
+
+	calls := 0
+	resp, err := policy.Execute(context.Background(), func() (*http.Response, error) {
+		calls++
+		if calls < 3 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.StatusCode, http.StatusOK)
+	assertEqual(t, calls, 3)
+
+	sleeps := clock.Sleeps()
+	assertEqual(t, len(sleeps), 2)
+	assertEqual(t, sleeps[0], time.Second)
+	assertEqual(t, sleeps[1], 2*time.Second)
+}
+
+func TestRetryPolicyExecuteExhausted(t *testing.T) {
+	useFakeClock(t)
+
+	policy := trip.NewRetryPolicy(3, trip.ConstantBackoff(time.Second), trip.RetryExhaustedError())
+	errNetwork := errors.New("network error")
+
+	calls := 0
+	_, err := policy.Execute(context.Background(), func() (*http.Response, error) {
+		calls++
+		return nil, errNetwork
+	})
+
+	assertEqual(t, calls, 3)
+	assertEqual(t, errors.Is(err, trip.ErrRetriesExhausted), true)
+	assertEqual(t, errors.Is(err, errNetwork), true)
+}
+
+func TestRetryPolicyExecuteCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := trip.NewRetryPolicy(3, trip.ConstantBackoff(time.Hour))
+
+	calls := 0
+	_, err := policy.Execute(ctx, func() (*http.Response, error) {
+		calls++
+		cancel()
+		return nil, errors.New("network error")
+	})
+
+	assertEqual(t, calls, 1)
+	assertEqual(t, err.Error(), "network error")
+}
+
+func TestRetryGetBodyError(t *testing.T) {
+	useFakeClock(t)
+	errRewind := errors.New("can't rewind")
+
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, errors.New("network error")
+	}), trip.Retry(3, time.Second))
+
+	req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("payload"))
+	req.GetBody = func() (io.ReadCloser, error) { return nil, errRewind }
+	_, err := transport.RoundTrip(req)
+
+	assertEqual(t, calls, 1)
+	assertEqual(t, err, errRewind)
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	for _, code := range []int{408, 425, 429, 500, 502, 503, 504} {
+		assertEqual(t, trip.IsRetryableStatus(code), true)
+	}
+	for _, code := range []int{0, 200, 301, 400, 404, 501, 505} {
+		assertEqual(t, trip.IsRetryableStatus(code), false)
+	}
+}
@@ -0,0 +1,133 @@
+package trip
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrHostNotAllowed is returned by AllowHosts for requests to hosts not on the allowlist.
+var ErrHostNotAllowed = errors.New("trip: host not allowed")
+
+// ErrPrivateIP is returned by BlockPrivateIPs for requests to private network addresses.
+var ErrPrivateIP = errors.New("trip: private ip address not allowed")
+
+// ErrInsecureScheme is returned by HTTPSOnly for requests not using https.
+var ErrInsecureScheme = errors.New("trip: insecure scheme, https required")
+
+// HTTPSOnly fails every request that does not use the https scheme with
+// ErrInsecureScheme, without sending it.
+func HTTPSOnly() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if !strings.EqualFold(r.URL.Scheme, "https") {
+				return nil, ErrInsecureScheme
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// UpgradeToHTTPS rewrites requests using the http scheme to https.
+// The default http port 80 is removed from the host.
+func UpgradeToHTTPS() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if strings.EqualFold(r.URL.Scheme, "http") {
+				r.URL.Host = stripDefaultPort("http", r.URL.Host)
+				r.Host = stripDefaultPort("http", r.Host)
+				r.URL.Scheme = "https"
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// AllowHosts fails every request to a host that is not on the given allowlist with
+// ErrHostNotAllowed, without sending it. Hosts are compared case-insensitively and
+// without port. A host starting with `*.` matches all of its subdomains, e.g.
+// `*.example.com` matches `api.example.com` but not `example.com`.
+func AllowHosts(hosts ...string) TripFunc {
+	allowed := matchHosts(hosts)
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if !allowed(r.URL.Hostname()) {
+				return nil, ErrHostNotAllowed
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// matchHosts returns a function reporting whether a host matches one of the
+// given hosts, which may contain wildcard subdomains like `*.example.com`.
+func matchHosts(hosts []string) func(host string) bool {
+	var (
+		exact    = map[string]bool{}
+		suffixes []string
+	)
+	for _, host := range hosts {
+		host = normalizeHostname(host)
+		if strings.HasPrefix(host, "*.") {
+			suffixes = append(suffixes, host[1:])
+		} else {
+			exact[host] = true
+		}
+	}
+
+	return func(host string) bool {
+		host = normalizeHostname(host)
+		if exact[host] {
+			return true
+		}
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func normalizeHostname(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// BlockPrivateIPs fails every request to a host that is or resolves to a private,
+// loopback, link-local or unspecified IP address with ErrPrivateIP, without sending it.
+//
+// As the host is resolved again when connecting, this does not protect against DNS
+// servers answering differently on consecutive lookups.
+func BlockPrivateIPs() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			host := r.URL.Hostname()
+
+			var ips []net.IP
+			if ip := net.ParseIP(host); ip != nil {
+				ips = append(ips, ip)
+			} else {
+				addrs, err := net.DefaultResolver.LookupIPAddr(r.Context(), host)
+				if err != nil {
+					return nil, err
+				}
+				for _, addr := range addrs {
+					ips = append(ips, addr.IP)
+				}
+			}
+
+			for _, ip := range ips {
+				if isPrivateIP(ip) {
+					return nil, ErrPrivateIP
+				}
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
@@ -0,0 +1,97 @@
+package trip_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestHTTPSOnly(t *testing.T) {
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, nil
+	}), trip.HTTPSOnly())
+
+	_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	assertEqual(t, errors.Is(err, trip.ErrInsecureScheme), true)
+	assertEqual(t, calls, 0)
+
+	_, err = transport.RoundTrip(httptest.NewRequest("GET", "https://example.com/", nil))
+	assertEqual(t, err, nil)
+	assertEqual(t, calls, 1)
+}
+
+func TestUpgradeToHTTPS(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "http://example.com/foo?bar=yes", expected: "https://example.com/foo?bar=yes"},
+		{url: "http://example.com:80/foo", expected: "https://example.com/foo"},
+		{url: "http://example.com:8080/foo", expected: "https://example.com:8080/foo"},
+		{url: "https://example.com/foo", expected: "https://example.com/foo"},
+	}
+
+	for _, tt := range tests {
+		trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			assertEqual(t, r.URL.String(), tt.expected)
+			return nil, nil
+		}), trip.UpgradeToHTTPS()).RoundTrip(httptest.NewRequest("GET", tt.url, nil))
+	}
+}
+
+func TestAllowHosts(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{url: "https://example.com/", allowed: true},
+		{url: "https://EXAMPLE.com:8443/", allowed: true},
+		{url: "https://api.trusted.org/", allowed: true},
+		{url: "https://a.b.trusted.org/", allowed: true},
+		{url: "https://trusted.org/", allowed: false},
+		{url: "https://eviltrusted.org/", allowed: false},
+		{url: "https://api.example.com/", allowed: false},
+		{url: "http://169.254.169.254/", allowed: false},
+	}
+
+	transport := trip.New(trip.RoundTripperFunc(noop), trip.AllowHosts("example.com", "*.Trusted.org"))
+
+	for _, tt := range tests {
+		_, err := transport.RoundTrip(httptest.NewRequest("GET", tt.url, nil))
+		if errors.Is(err, trip.ErrHostNotAllowed) == tt.allowed {
+			t.Errorf("%s: got: %v, expected allowed: %v", tt.url, err, tt.allowed)
+		}
+	}
+}
+
+func TestBlockPrivateIPs(t *testing.T) {
+	tests := []struct {
+		url     string
+		blocked bool
+	}{
+		{url: "http://127.0.0.1/", blocked: true},
+		{url: "http://10.0.0.1:8080/", blocked: true},
+		{url: "http://192.168.1.1/", blocked: true},
+		{url: "http://169.254.169.254/", blocked: true},
+		{url: "http://[::1]/", blocked: true},
+		{url: "http://[fd00::1]/", blocked: true},
+		{url: "http://0.0.0.0/", blocked: true},
+		{url: "http://localhost/", blocked: true},
+		{url: "http://93.184.216.34/", blocked: false},
+		{url: "http://[2606:2800:220:1:248:1893:25c8:1946]/", blocked: false},
+	}
+
+	transport := trip.New(trip.RoundTripperFunc(noop), trip.BlockPrivateIPs())
+
+	for _, tt := range tests {
+		_, err := transport.RoundTrip(httptest.NewRequest("GET", tt.url, nil))
+		if errors.Is(err, trip.ErrPrivateIP) != tt.blocked {
+			t.Errorf("%s: got: %v, expected blocked: %v", tt.url, err, tt.blocked)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package trip
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// TransportSettings holds the settings of a Transport that can be changed at runtime.
+type TransportSettings struct {
+	// RetryAttempts is the number of attempts of a failed request, see Retry.
+	// Values below 2 disable retries.
+	RetryAttempts int
+
+	// RetryDelay is the delay between attempts of a failed request.
+	RetryDelay time.Duration
+
+	// RetryStatusCodes are the HTTP status codes that are considered as failure case.
+	RetryStatusCodes []int
+
+	// Timeout limits the time of a request, including all of its attempts and reading
+	// the response body. Zero means no timeout.
+	Timeout time.Duration
+
+	// Log is the function every attempt is logged with, see Logger. If nil, requests
+	// are not logged.
+	Log func(format string, v ...any)
+}
+
+// Transport is an http.RoundTripper whose retry, timeout and logging settings can be
+// swapped atomically while it is in use. Requests already in flight keep the settings
+// they were started with. Use New or Default for a transport that is configured once.
+type Transport struct {
+	base  http.RoundTripper
+	chain atomic.Pointer[settingsChain]
+}
+
+type settingsChain struct {
+	settings TransportSettings
+	rt       http.RoundTripper
+}
+
+// NewTransport creates a new Transport sending requests through base with the given
+// settings. If base is nil, the http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, settings TransportSettings) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{base: base}
+	t.SetSettings(settings)
+	return t
+}
+
+// Settings returns the current settings of t.
+func (t *Transport) Settings() TransportSettings {
+	s := t.chain.Load().settings
+	s.RetryStatusCodes = append([]int(nil), s.RetryStatusCodes...)
+	return s
+}
+
+// SetSettings replaces the settings of t. They apply to all requests started afterwards.
+func (t *Transport) SetSettings(settings TransportSettings) {
+	settings.RetryStatusCodes = append([]int(nil), settings.RetryStatusCodes...)
+
+	var trips []TripFunc
+	if settings.Log != nil {
+		trips = append(trips, Logger(settings.Log))
+	}
+	if settings.RetryAttempts > 1 {
+		trips = append(trips, Retry(settings.RetryAttempts, settings.RetryDelay, settings.RetryStatusCodes...))
+	}
+	t.chain.Store(&settingsChain{settings: settings, rt: New(t.base, trips...)})
+}
+
+// Unwrap returns the transport requests are sent through, see UnwrapTransport.
+func (t *Transport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+// RoundTrip satisfies http.RoundTripper and sends r with the current settings.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c := t.chain.Load()
+	if c.settings.Timeout <= 0 {
+		return c.rt.RoundTrip(r)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), c.settings.Timeout)
+	resp, err := c.rt.RoundTrip(r.WithContext(ctx))
+	if err != nil || resp == nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, err
+}
+
+// cancelBody cancels the context of a request when its response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,66 @@
+package trip_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestTransportSetSettings(t *testing.T) {
+	useFakeClock(t)
+
+	calls := 0
+	transport := trip.NewTransport(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.TransportSettings{RetryAttempts: 2, RetryDelay: time.Second, RetryStatusCodes: trip.RetryableStatusCodes})
+
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	assertEqual(t, calls, 2)
+
+	settings := transport.Settings()
+	settings.RetryAttempts = 4
+	transport.SetSettings(settings)
+
+	calls = 0
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	assertEqual(t, calls, 4)
+}
+
+func TestTransportLog(t *testing.T) {
+	var lines []string
+	transport := trip.NewTransport(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{Status: "200 OK", StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), trip.TransportSettings{Log: func(format string, v ...any) {
+		lines = append(lines, fmt.Sprintf(format, v...))
+	}})
+
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	assertEqual(t, len(lines), 1)
+	assertPrefix(t, lines[0], "GET http://example.com/ - 200 OK -")
+}
+
+func TestTransportTimeout(t *testing.T) {
+	transport := trip.NewTransport(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	}), trip.TransportSettings{Timeout: 10 * time.Millisecond})
+
+	_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	assertEqual(t, errors.Is(err, context.DeadlineExceeded), true)
+}
+
+func TestTransportUnwrap(t *testing.T) {
+	base := &http.Transport{}
+	transport, ok := trip.UnwrapTransport(trip.NewTransport(base, trip.TransportSettings{}))
+	assertEqual(t, ok, true)
+	assertEqual(t, transport, base)
+}
@@ -0,0 +1,57 @@
+package trip_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestSignEd25519(t *testing.T) {
+	useFakeClock(t)
+
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+
+	req := httptest.NewRequest("POST", "http://example.com/foo?bar=yes", strings.NewReader("{\"hello\": \"world\"}\n"))
+	req.Header.Set("Content-Type", "application/json")
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		assertEqual(t, string(body), "{\"hello\": \"world\"}\n")
+		assertEqual(t, r.Header.Get("Content-Digest"), "sha-256=:RK/0qy18MlBSVnWgjwz6lZEWjP/lF5HF9bvEF8FabDg=:")
+
+		params := `("@method" "@path" "content-type" "content-digest");created=1640995200;keyid="mesh-key";alg="ed25519"`
+		assertEqual(t, r.Header.Get("Signature-Input"), "sig1="+params)
+
+		base := `"@method": POST` + "\n" +
+			`"@path": /foo` + "\n" +
+			`"content-type": application/json` + "\n" +
+			`"content-digest": sha-256=:RK/0qy18MlBSVnWgjwz6lZEWjP/lF5HF9bvEF8FabDg=:` + "\n" +
+			`"@signature-params": ` + params
+
+		signature := r.Header.Get("Signature")
+		assertPrefix(t, signature, "sig1=:")
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(signature, "sig1=:"), ":"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, ed25519.Verify(priv.Public().(ed25519.PublicKey), []byte(base), sig), true)
+		return nil, nil
+	}), trip.SignEd25519("mesh-key", priv, []string{"Content-Type"})).RoundTrip(req)
+}
+
+func TestSignEd25519MissingHeader(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(nil)
+
+	_, err := roundTrip(noop, trip.SignEd25519("mesh-key", priv, []string{"X-Tenant"}))
+	assertEqual(t, err.Error(), `trip: header "x-tenant" to sign is missing`)
+}
@@ -0,0 +1,158 @@
+package trip
+
+import (
+	"encoding/hex"
+	"expvar"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stat describes a completed request.
+type Stat struct {
+	Method     string
+	Host       string
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+
+	// Retries is the number of times the request was retried by Retry.
+	Retries int
+
+	// TraceID is the hex encoded ID of the trace the request belongs to (see
+	// ContextWithSpan), e.g. to attach an exemplar to a latency histogram. It is empty
+	// if the request context carries no span.
+	TraceID string
+}
+
+// Observe calls f with the statistics of every completed request. If placed after Retry
+// in the list of trip functions, f is called once per request with all attempts
+// included. If placed before Retry, f is called for every attempt.
+func Observe(f func(Stat)) TripFunc {
+	if f == nil {
+		panic("trip: observe function is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			ctx, counter := withAttemptCounter(r.Context())
+			start := clk.Now()
+
+			resp, err := send(t, r.WithContext(ctx))
+
+			stat := Stat{
+				Method:   r.Method,
+				Host:     r.URL.Host,
+				Duration: since(start),
+				Err:      err,
+				Retries:  retries(r.Context(), counter),
+			}
+			if err == nil {
+				stat.StatusCode = resp.StatusCode
+			}
+			if sc, ok := SpanFromContext(r.Context()); ok {
+				stat.TraceID = hex.EncodeToString(sc.TraceID[:])
+			}
+			f(stat)
+
+			return resp, err
+		})
+	}
+}
+
+// ByteCount calls f with the number of bytes of the request body sent and the number of
+// bytes of the response body read, once the response body is closed. The counts refer
+// to the bodies only, headers are not included. If the request fails, f is called
+// right away with nothing received.
+func ByteCount(f func(sent, received int64)) TripFunc {
+	if f == nil {
+		panic("trip: byte count function is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			sent := new(atomic.Int64)
+			if hasBody(r) {
+				r.Body = &countingBody{ReadCloser: r.Body, n: sent}
+			}
+
+			resp, err := send(t, r)
+			if err != nil || resp.Body == nil {
+				f(sent.Load(), 0)
+				return resp, err
+			}
+
+			body := &countingBody{ReadCloser: resp.Body, n: new(atomic.Int64)}
+			body.onClose = func() { f(sent.Load(), body.n.Load()) }
+			resp.Body = body
+			return resp, err
+		})
+	}
+}
+
+// countingBody counts the bytes read from a body into n and calls onClose, if set,
+// when the body is closed for the first time.
+type countingBody struct {
+	io.ReadCloser
+	n       *atomic.Int64
+	onClose func()
+	once    sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.onClose != nil {
+		b.once.Do(b.onClose)
+	}
+	return err
+}
+
+// ExpvarStats publishes request statistics per HTTP method as an expvar.Map under the
+// given name. Every method entry holds the number of requests (`count`), failed
+// requests (`errors`), and the total and maximum duration in milliseconds (`total_ms`,
+// `max_ms`). If a map with the given name is already published, it is reused.
+func ExpvarStats(name string) TripFunc {
+	stats, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		stats = expvar.NewMap(name)
+	}
+
+	var mu sync.Mutex
+	record := func(method string, d time.Duration, failed bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		m, ok := stats.Get(method).(*expvar.Map)
+		if !ok {
+			m = new(expvar.Map)
+			stats.Set(method, m)
+		}
+
+		ms := float64(d) / float64(time.Millisecond)
+		m.Add("count", 1)
+		if failed {
+			m.Add("errors", 1)
+		}
+		m.AddFloat("total_ms", ms)
+		if max, ok := m.Get("max_ms").(*expvar.Float); !ok || max.Value() < ms {
+			f := new(expvar.Float)
+			f.Set(ms)
+			m.Set("max_ms", f)
+		}
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			start := clk.Now()
+			resp, err := t.RoundTrip(r)
+			record(r.Method, since(start), err != nil)
+			return resp, err
+		})
+	}
+}
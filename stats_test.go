@@ -0,0 +1,161 @@
+package trip_test
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestExpvarStats(t *testing.T) {
+	clock := useFakeClock(t)
+	durations := []time.Duration{10 * time.Millisecond, 30 * time.Millisecond, 20 * time.Millisecond}
+	calls := 0
+	name := fmt.Sprintf("trip_test_stats_%d", time.Now().UnixNano())
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		clock.Advance(durations[calls])
+		calls++
+		if r.Method == "POST" {
+			return nil, errors.New("network error")
+		}
+		return nil, nil
+	}), trip.ExpvarStats(name))
+
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	transport.RoundTrip(httptest.NewRequest("POST", "http://example.com/", nil))
+
+	stats := expvar.Get(name).(*expvar.Map)
+
+	get := stats.Get("GET").(*expvar.Map)
+	assertEqual(t, get.Get("count").String(), "2")
+	assertEqual(t, get.Get("errors"), nil)
+	assertEqual(t, get.Get("total_ms").String(), "40")
+	assertEqual(t, get.Get("max_ms").String(), "30")
+
+	post := stats.Get("POST").(*expvar.Map)
+	assertEqual(t, post.Get("count").String(), "1")
+	assertEqual(t, post.Get("errors").String(), "1")
+	assertEqual(t, post.Get("total_ms").String(), "20")
+}
+
+func TestObserve(t *testing.T) {
+	clock := useFakeClock(t)
+	var stat trip.Stat
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		clock.Advance(10 * time.Millisecond)
+		return &http.Response{StatusCode: 201}, nil
+	}, trip.Observe(func(s trip.Stat) { stat = s }))
+
+	assertEqual(t, stat.Method, "POST")
+	assertEqual(t, stat.Host, "example.com")
+	assertEqual(t, stat.StatusCode, 201)
+	assertEqual(t, stat.Duration, 10*time.Millisecond)
+	assertEqual(t, stat.Err, nil)
+	assertEqual(t, stat.Retries, 0)
+}
+
+func TestObserveTraceID(t *testing.T) {
+	var stats []trip.Stat
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}), trip.Observe(func(s trip.Stat) { stats = append(stats, s) }))
+
+	sc := trip.SpanContext{TraceID: [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}}
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	transport.RoundTrip(req.WithContext(trip.ContextWithSpan(req.Context(), sc)))
+	transport.RoundTrip(req)
+
+	assertEqual(t, stats[0].TraceID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	assertEqual(t, stats[1].TraceID, "")
+}
+
+func TestObserveError(t *testing.T) {
+	var (
+		stat       trip.Stat
+		calls      int
+		errNetwork = errors.New("network error")
+	)
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, errNetwork
+	}, trip.Retry(3, time.Millisecond), trip.Observe(func(s trip.Stat) { stat = s }))
+
+	assertEqual(t, calls, 3)
+	assertEqual(t, stat.StatusCode, 0)
+	assertEqual(t, stat.Err, errNetwork)
+	assertEqual(t, stat.Retries, 2)
+}
+
+func TestObserveNested(t *testing.T) {
+	useFakeClock(t)
+
+	var inner, outer trip.Stat
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("network error")
+	}, trip.Retry(3, time.Millisecond), trip.Observe(func(s trip.Stat) { inner = s }), trip.Observe(func(s trip.Stat) { outer = s }))
+
+	assertEqual(t, inner.Retries, 2)
+	assertEqual(t, outer.Retries, 2)
+}
+
+func TestObservePerAttempt(t *testing.T) {
+	var stats []trip.Stat
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("network error")
+	}, trip.Observe(func(s trip.Stat) { stats = append(stats, s) }), trip.Retry(3, time.Millisecond))
+
+	assertEqual(t, len(stats), 3)
+	for i, stat := range stats {
+		assertEqual(t, stat.Retries, i)
+	}
+}
+
+func TestByteCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, strings.Repeat("x", 2048))
+	}))
+	defer server.Close()
+
+	var sent, received int64
+	calls := 0
+	client := &http.Client{Transport: trip.Default(trip.ByteCount(func(s, r int64) {
+		sent, received = s, r
+		calls++
+	}))}
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader(strings.Repeat("y", 1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	assertEqual(t, calls, 0)
+	resp.Body.Close()
+	resp.Body.Close()
+
+	assertEqual(t, calls, 1)
+	assertEqual(t, sent, int64(1000))
+	assertEqual(t, received, int64(2048))
+
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assertEqual(t, sent, int64(0))
+	assertEqual(t, received, int64(2048))
+}
@@ -0,0 +1,28 @@
+package trip
+
+import "net/http"
+
+// Stub returns the response returned by match for requests it matches, without sending
+// them through the transport. Other requests are sent as usual. This allows serving
+// canned responses for specific endpoints, e.g. in offline demos. The request of a
+// stubbed response is set to the matched request if missing.
+func Stub(match func(*http.Request) (*http.Response, bool)) TripFunc {
+	if match == nil {
+		panic("trip: stub function is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, ok := match(r)
+			if !ok {
+				return t.RoundTrip(r)
+			}
+			if resp == nil {
+				return nil, ErrNilResponse
+			}
+			if resp.Request == nil {
+				resp.Request = r
+			}
+			return resp, nil
+		})
+	}
+}
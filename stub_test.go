@@ -0,0 +1,31 @@
+package trip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestStub(t *testing.T) {
+	calls := 0
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 502, Body: http.NoBody}, nil
+	}), trip.Stub(func(r *http.Request) (*http.Response, bool) {
+		if r.URL.Path != "/health" {
+			return nil, false
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, true
+	}))
+
+	resp, _ := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/health", nil))
+	assertEqual(t, resp.StatusCode, 200)
+	assertEqual(t, resp.Request.URL.Path, "/health")
+	assertEqual(t, calls, 0)
+
+	resp, _ = transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/users", nil))
+	assertEqual(t, resp.StatusCode, 502)
+	assertEqual(t, calls, 1)
+}
@@ -0,0 +1,205 @@
+package trip
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ClientTrace attaches the given trace hooks to every request. Hooks of traces already
+// attached to the request context are called as well.
+func ClientTrace(trace *httptrace.ClientTrace) TripFunc {
+	if trace == nil {
+		panic("trip: client trace is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return t.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+		})
+	}
+}
+
+// ConnectionInfo calls f for every connection a request is sent on, reporting whether
+// an idle connection was reused and how long it was idle before. A new connection is
+// reported with reused false and no idle time. Note that the response body must be
+// read to the end and closed for a connection to be reused.
+func ConnectionInfo(f func(reused bool, idleTime time.Duration)) TripFunc {
+	if f == nil {
+		panic("trip: connection info function is nil")
+	}
+	return ClientTrace(&httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			f(info.Reused, info.IdleTime)
+		},
+	})
+}
+
+// SpanContext identifies the span of a distributed trace a request belongs to.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying the given span context, which is
+// then propagated by trip functions like B3Propagation.
+func ContextWithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// SpanFromContext returns the span context stored in ctx by ContextWithSpan.
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanKey{}).(SpanContext)
+	return sc, ok
+}
+
+// B3Propagation sets the Zipkin B3 single `b3` header on every request to
+// `{traceId}-{spanId}-{samplingState}`, using the span context of the request
+// (see ContextWithSpan). If the request has no span context, a new sampled
+// trace is started with random IDs.
+func B3Propagation() TripFunc {
+	return b3Propagation(false)
+}
+
+// B3MultiPropagation works like B3Propagation, but additionally sets the
+// `X-B3-TraceId`, `X-B3-SpanId` and `X-B3-Sampled` headers for servers that
+// only understand the multi header variant.
+func B3MultiPropagation() TripFunc {
+	return b3Propagation(true)
+}
+
+func b3Propagation(multi bool) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			sc, ok := SpanFromContext(r.Context())
+			if !ok {
+				readRand(sc.TraceID[:])
+				readRand(sc.SpanID[:])
+				sc.Sampled = true
+			}
+
+			var (
+				traceID = hex.EncodeToString(sc.TraceID[:])
+				spanID  = hex.EncodeToString(sc.SpanID[:])
+				sampled = "0"
+			)
+			if sc.Sampled {
+				sampled = "1"
+			}
+
+			r.Header.Set("B3", traceID+"-"+spanID+"-"+sampled)
+			if multi {
+				r.Header.Set("X-B3-TraceId", traceID)
+				r.Header.Set("X-B3-SpanId", spanID)
+				r.Header.Set("X-B3-Sampled", sampled)
+			}
+
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+type baggageKey struct{}
+
+// ContextWithBaggage returns a copy of ctx carrying the given baggage items in addition
+// to those already carried by ctx, which are then propagated by Baggage. Items with the
+// same key replace those of ctx.
+func ContextWithBaggage(ctx context.Context, items map[string]string) context.Context {
+	parent, _ := BaggageFromContext(ctx)
+	merged := make(map[string]string, len(parent)+len(items))
+	for key, value := range parent {
+		merged[key] = value
+	}
+	for key, value := range items {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, baggageKey{}, merged)
+}
+
+// BaggageFromContext returns the baggage items stored in ctx by ContextWithBaggage.
+// The returned map must not be modified.
+func BaggageFromContext(ctx context.Context) (map[string]string, bool) {
+	items, ok := ctx.Value(baggageKey{}).(map[string]string)
+	return items, ok
+}
+
+// Baggage adds the given items and those stored in the request context by
+// ContextWithBaggage to the W3C `baggage` header of every request. Values are
+// percent-encoded as required by the W3C Baggage specification. Entries already
+// present in the request's baggage header take precedence over items with the same
+// key, and items of the request context take precedence over the given items.
+func Baggage(items map[string]string) TripFunc {
+	keys := sortedKeys(items)
+
+	members := make([]string, len(keys))
+	for i, key := range keys {
+		members[i] = key + "=" + encodeBaggageValue(items[key])
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			existing := r.Header.Values("Baggage")
+			present := map[string]bool{}
+			for _, header := range existing {
+				for _, member := range strings.Split(header, ",") {
+					key, _, _ := strings.Cut(member, "=")
+					present[strings.TrimSpace(key)] = true
+				}
+			}
+
+			merged := append([]string(nil), existing...)
+			if ctxItems, ok := BaggageFromContext(r.Context()); ok {
+				for _, key := range sortedKeys(ctxItems) {
+					if !present[key] {
+						merged = append(merged, key+"="+encodeBaggageValue(ctxItems[key]))
+						present[key] = true
+					}
+				}
+			}
+			for i, key := range keys {
+				if !present[key] {
+					merged = append(merged, members[i])
+				}
+			}
+			if len(merged) > 0 {
+				r.Header.Set("Baggage", strings.Join(merged, ","))
+			}
+
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeBaggageValue percent-encodes all characters of v that are not allowed
+// unencoded in a baggage value.
+func encodeBaggageValue(v string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c > 0x20 && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
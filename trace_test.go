@@ -0,0 +1,160 @@
+package trip_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestBaggage(t *testing.T) {
+	items := map[string]string{
+		"tenant":  "acme corp",
+		"flags":   "a,b;c=\"d\"",
+		"percent": "100%",
+	}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		header := r.Header.Get("Baggage")
+		assertEqual(t, header, "flags=a%2Cb%3Bc=%22d%22,percent=100%25,tenant=acme%20corp")
+
+		parsed := parseBaggage(t, header)
+		assertEqual(t, len(parsed), len(items))
+		for key, value := range items {
+			assertEqual(t, parsed[key], value)
+		}
+		return nil, nil
+	}, trip.Baggage(items))
+}
+
+func TestBaggageMerge(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Baggage", "tenant=other;prop,user=42")
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		parsed := parseBaggage(t, r.Header.Get("Baggage"))
+		assertEqual(t, len(parsed), 3)
+		assertEqual(t, parsed["tenant"], "other")
+		assertEqual(t, parsed["user"], "42")
+		assertEqual(t, parsed["region"], "eu")
+		return nil, nil
+	}), trip.Baggage(map[string]string{"tenant": "acme", "region": "eu"})).RoundTrip(req)
+}
+
+func TestBaggageFromContext(t *testing.T) {
+	ctx := trip.ContextWithBaggage(context.Background(), map[string]string{"tenant": "acme", "user": "1"})
+	ctx = trip.ContextWithBaggage(ctx, map[string]string{"user": "42"})
+	req := httptest.NewRequest("GET", "http://example.com/", nil).WithContext(ctx)
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Baggage"), "tenant=acme,user=42,region=eu")
+		return nil, nil
+	}), trip.Baggage(map[string]string{"tenant": "other", "region": "eu"})).RoundTrip(req)
+}
+
+// parseBaggage parses a W3C baggage header, ignoring member properties.
+func parseBaggage(t *testing.T, header string) map[string]string {
+	parsed := map[string]string{}
+	for _, member := range strings.Split(header, ",") {
+		member, _, _ = strings.Cut(member, ";")
+		key, value, ok := strings.Cut(member, "=")
+		if !ok {
+			t.Fatalf("invalid baggage member: %q", member)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			t.Fatalf("invalid baggage value: %q", value)
+		}
+		parsed[strings.TrimSpace(key)] = decoded
+	}
+	return parsed
+}
+
+func TestB3Propagation(t *testing.T) {
+	sc := trip.SpanContext{
+		TraceID: [16]byte{0x46, 0x3a, 0xc3, 0x5c, 0x9f, 0x64, 0x13, 0xad, 0x48, 0x48, 0x5a, 0x39, 0x53, 0xbb, 0x61, 0x24},
+		SpanID:  [8]byte{0xa2, 0xfb, 0x46, 0x44, 0x74, 0x31, 0x2a, 0x00},
+		Sampled: true,
+	}
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req = req.WithContext(trip.ContextWithSpan(context.Background(), sc))
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("b3"), "463ac35c9f6413ad48485a3953bb6124-a2fb464474312a00-1")
+		assertEqual(t, r.Header.Get("X-B3-TraceId"), "")
+		return nil, nil
+	}), trip.B3Propagation()).RoundTrip(req)
+}
+
+func TestB3PropagationGenerated(t *testing.T) {
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		matched, _ := regexp.MatchString("^[0-9a-f]{32}-[0-9a-f]{16}-1$", r.Header.Get("b3"))
+		assertEqual(t, matched, true)
+		return nil, nil
+	}, trip.B3Propagation())
+}
+
+func TestB3MultiPropagation(t *testing.T) {
+	sc := trip.SpanContext{TraceID: [16]byte{1}, SpanID: [8]byte{2}}
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req = req.WithContext(trip.ContextWithSpan(context.Background(), sc))
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("b3"), "01000000000000000000000000000000-0200000000000000-0")
+		assertEqual(t, r.Header.Get("X-B3-TraceId"), "01000000000000000000000000000000")
+		assertEqual(t, r.Header.Get("X-B3-SpanId"), "0200000000000000")
+		assertEqual(t, r.Header.Get("X-B3-Sampled"), "0")
+		return nil, nil
+	}), trip.B3MultiPropagation()).RoundTrip(req)
+}
+
+func TestClientTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var firstByte, existing bool
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { firstByte = true },
+	}
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { existing = true },
+	})
+
+	client := &http.Client{Transport: trip.Default(trip.ClientTrace(trace))}
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	assertEqual(t, firstByte, true)
+	assertEqual(t, existing, true)
+}
+
+func TestConnectionInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	defer server.Close()
+
+	var reused []bool
+	client := &http.Client{Transport: trip.New(&http.Transport{}, trip.ConnectionInfo(func(r bool, idle time.Duration) {
+		reused = append(reused, r)
+	}))}
+
+	get(t, client, server.URL)
+	get(t, client, server.URL)
+
+	assertEqual(t, len(reused), 2)
+	assertEqual(t, reused[0], false)
+	assertEqual(t, reused[1], true)
+}
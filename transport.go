@@ -0,0 +1,209 @@
+package trip
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Proxy routes requests through the proxy returned by selector. If selector returns
+// a nil URL, the request is sent directly. If it returns an error, the request fails.
+// Proxy configures a copy of the wrapped *http.Transport and should therefore be placed
+// first in the list of trip functions, see TransportWrapper.
+func Proxy(selector func(*http.Request) (*url.URL, error)) TripFunc {
+	if selector == nil {
+		panic("trip: proxy selector is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return configureTransport("Proxy", t, func(transport *http.Transport) {
+			transport.Proxy = selector
+		})
+	}
+}
+
+// DisableCompression stops the wrapped transport from requesting and transparently
+// decompressing gzip responses, so that compressed bodies are delivered untouched
+// together with their `Content-Encoding` header. It only affects a copy of the
+// wrapped *http.Transport and should therefore be placed first in the list of trip
+// functions, see TransportWrapper.
+func DisableCompression() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return configureTransport("DisableCompression", t, func(transport *http.Transport) {
+			transport.DisableCompression = true
+		})
+	}
+}
+
+// MinTLSVersion sets the minimum TLS version accepted when connecting to a server,
+// e.g. tls.VersionTLS12. Other TLS settings are preserved. It configures a copy of
+// the wrapped *http.Transport and should therefore be placed first in the list of
+// trip functions, see TransportWrapper.
+func MinTLSVersion(version uint16) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return configureTransport("MinTLSVersion", t, func(transport *http.Transport) {
+			tlsConfig(transport).MinVersion = version
+		})
+	}
+}
+
+// RootCAs sets the certificate authorities used to verify server certificates, e.g. to
+// connect to services using a private CA. It configures a copy of the wrapped
+// *http.Transport and should therefore be placed first in the list of trip functions,
+// see TransportWrapper.
+func RootCAs(pool *x509.CertPool) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return configureTransport("RootCAs", t, func(transport *http.Transport) {
+			tlsConfig(transport).RootCAs = pool
+		})
+	}
+}
+
+// ErrCertificateNotPinned is returned when a server's certificate matches none of the
+// pins given to PinCertificates.
+var ErrCertificateNotPinned = errors.New("trip: server certificate does not match any pin")
+
+// PinCertificates only accepts connections to servers whose leaf certificate public key
+// matches one of the given pins. A pin is the base64 encoded SHA-256 hash of a
+// certificate's SubjectPublicKeyInfo, as used by HPKP:
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+//
+// The usual certificate chain verification still applies. It configures a copy of the
+// wrapped *http.Transport and should therefore be placed first in the list of trip
+// functions, see TransportWrapper.
+func PinCertificates(pins ...string) TripFunc {
+	pinned := map[string]bool{}
+	for _, pin := range pins {
+		pinned[pin] = true
+	}
+
+	return func(t http.RoundTripper) http.RoundTripper {
+		return configureTransport("PinCertificates", t, func(transport *http.Transport) {
+			config := tlsConfig(transport)
+
+			verify := config.VerifyConnection
+			config.VerifyConnection = func(cs tls.ConnectionState) error {
+				if verify != nil {
+					if err := verify(cs); err != nil {
+						return err
+					}
+				}
+				if len(cs.PeerCertificates) == 0 {
+					return ErrCertificateNotPinned
+				}
+				sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+				if !pinned[base64.StdEncoding.EncodeToString(sum[:])] {
+					return ErrCertificateNotPinned
+				}
+				return nil
+			}
+		})
+	}
+}
+
+// TransportOption configures an *http.Transport for TransportConfig.
+type TransportOption func(*http.Transport)
+
+// DialTimeout limits the time spent establishing a TCP connection.
+func DialTimeout(d time.Duration) TransportOption {
+	return func(t *http.Transport) {
+		t.DialContext = (&net.Dialer{Timeout: d, KeepAlive: 30 * time.Second}).DialContext
+	}
+}
+
+// TLSHandshakeTimeout limits the time spent performing the TLS handshake.
+func TLSHandshakeTimeout(d time.Duration) TransportOption {
+	return func(t *http.Transport) {
+		t.TLSHandshakeTimeout = d
+	}
+}
+
+// IdleConnTimeout limits the time an idle connection is kept open for reuse.
+func IdleConnTimeout(d time.Duration) TransportOption {
+	return func(t *http.Transport) {
+		t.IdleConnTimeout = d
+	}
+}
+
+// MaxIdleConns limits the number of idle connections kept open across all hosts.
+func MaxIdleConns(n int) TransportOption {
+	return func(t *http.Transport) {
+		t.MaxIdleConns = n
+	}
+}
+
+// TransportConfig applies the given options to a copy of the wrapped *http.Transport
+// and should therefore be placed first in the list of trip functions, see
+// TransportWrapper.
+func TransportConfig(opts ...TransportOption) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return configureTransport("TransportConfig", t, func(transport *http.Transport) {
+			for _, opt := range opts {
+				opt(transport)
+			}
+		})
+	}
+}
+
+// tlsConfig returns the TLS config of transport, creating it if necessary.
+func tlsConfig(transport *http.Transport) *tls.Config {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	return transport.TLSClientConfig
+}
+
+// TransportWrapper is implemented by transports that wrap another transport, like
+// Transport. Trip functions configuring the *http.Transport, like Proxy or
+// MinTLSVersion, usually wrap it directly and configure a copy of it. Placed after a
+// TransportWrapper instead, they look through it and configure the *http.Transport
+// underneath in place, so it must not be shared with other clients. The
+// http.DefaultTransport is never configured in place. Such trip functions panic if
+// no *http.Transport is found.
+type TransportWrapper interface {
+	http.RoundTripper
+
+	// Unwrap returns the wrapped transport.
+	Unwrap() http.RoundTripper
+}
+
+// UnwrapTransport returns the *http.Transport rt is or wraps through TransportWrappers.
+// It reports false if no *http.Transport is found.
+func UnwrapTransport(rt http.RoundTripper) (*http.Transport, bool) {
+	for {
+		switch t := rt.(type) {
+		case *http.Transport:
+			return t, true
+		case TransportWrapper:
+			rt = t.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// configureTransport applies configure to a copy of t, if t is an *http.Transport, and
+// returns the copy. If t is a TransportWrapper, the *http.Transport underneath is
+// configured in place and t is returned unchanged.
+func configureTransport(name string, t http.RoundTripper, configure func(*http.Transport)) http.RoundTripper {
+	if transport, ok := t.(*http.Transport); ok {
+		transport = transport.Clone()
+		configure(transport)
+		return transport
+	}
+	transport, ok := UnwrapTransport(t)
+	if !ok {
+		panic("trip: " + name + " requires an *http.Transport to wrap")
+	}
+	if transport == http.DefaultTransport {
+		panic("trip: " + name + " cannot configure http.DefaultTransport in place")
+	}
+	configure(transport)
+	return t
+}
@@ -0,0 +1,287 @@
+package trip_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/philippta/trip"
+)
+
+func TestProxy(t *testing.T) {
+	proxied := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		assertEqual(t, r.URL.String(), "http://matched.example/foo")
+		io.WriteString(w, "proxy")
+	}))
+	defer proxy.Close()
+
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer direct.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	selector := func(r *http.Request) (*url.URL, error) {
+		if r.URL.Hostname() == "matched.example" {
+			return proxyURL, nil
+		}
+		return nil, nil
+	}
+
+	client := &http.Client{Transport: trip.New(&http.Transport{}, trip.Proxy(selector))}
+
+	assertEqual(t, get(t, client, "http://matched.example/foo"), "proxy")
+	assertEqual(t, get(t, client, direct.URL), "direct")
+	assertEqual(t, proxied, 1)
+}
+
+func TestProxyError(t *testing.T) {
+	errNoProxy := errors.New("no proxy")
+	selector := func(r *http.Request) (*url.URL, error) {
+		return nil, errNoProxy
+	}
+
+	client := &http.Client{Transport: trip.New(&http.Transport{}, trip.Proxy(selector))}
+	_, err := client.Get("http://example.com/")
+
+	if !errors.Is(err, errNoProxy) {
+		t.Errorf("got: %v, expected: %v", err, errNoProxy)
+	}
+}
+
+func TestProxyRequiresTransport(t *testing.T) {
+	defer func() {
+		assertEqual(t, recover(), any("trip: Proxy requires an *http.Transport to wrap"))
+	}()
+	trip.New(trip.RoundTripperFunc(noop), trip.Proxy(http.ProxyFromEnvironment))
+}
+
+func TestDisableCompression(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write([]byte("hello"))
+	w.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	base := &http.Transport{}
+
+	client := &http.Client{Transport: trip.New(base, trip.DisableCompression())}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, resp.Header.Get("Content-Encoding"), "gzip")
+	assertEqual(t, bytes.Equal(body, compressed.Bytes()), true)
+	assertEqual(t, base.DisableCompression, false)
+}
+
+func TestMinTLSVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	base := server.Client().Transport
+
+	client := &http.Client{Transport: trip.New(base, trip.MinTLSVersion(tls.VersionTLS12))}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	client = &http.Client{Transport: trip.New(base, trip.MinTLSVersion(tls.VersionTLS13))}
+	_, err = client.Get(server.URL)
+	assertNotEqual(t, err, nil)
+
+	assertEqual(t, base.(*http.Transport).TLSClientConfig.MinVersion, uint16(0))
+	assertNotEqual(t, base.(*http.Transport).TLSClientConfig.RootCAs, nil)
+}
+
+func TestRootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The httptest server certificate is a self-signed CA certificate.
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	client := &http.Client{Transport: trip.New(&http.Transport{}, trip.RootCAs(pool))}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	client = &http.Client{Transport: trip.New(&http.Transport{})}
+	_, err = client.Get(server.URL)
+	assertNotEqual(t, err, nil)
+}
+
+func TestRootCAsRequiresTransport(t *testing.T) {
+	defer func() {
+		assertEqual(t, recover(), any("trip: RootCAs requires an *http.Transport to wrap"))
+	}()
+	trip.New(trip.RoundTripperFunc(noop), trip.RootCAs(x509.NewCertPool()))
+}
+
+func TestPinCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+	other := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	client := &http.Client{Transport: trip.New(server.Client().Transport, trip.PinCertificates(other, pin))}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	client = &http.Client{Transport: trip.New(server.Client().Transport, trip.PinCertificates(other))}
+	_, err = client.Get(server.URL)
+	assertEqual(t, errors.Is(err, trip.ErrCertificateNotPinned), true)
+}
+
+func TestPinCertificatesVerifiesChain(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+
+	client := &http.Client{Transport: trip.New(&http.Transport{}, trip.PinCertificates(pin))}
+	_, err := client.Get(server.URL)
+	assertNotEqual(t, err, nil)
+	assertEqual(t, errors.Is(err, trip.ErrCertificateNotPinned), false)
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestTransportConfig(t *testing.T) {
+	base := &http.Transport{}
+	rt := trip.New(base, trip.TransportConfig(
+		trip.DialTimeout(time.Second),
+		trip.TLSHandshakeTimeout(2*time.Second),
+		trip.IdleConnTimeout(3*time.Second),
+		trip.MaxIdleConns(4),
+	))
+
+	transport := rt.(*http.Transport)
+	assertNotEqual(t, transport, base)
+	assertEqual(t, transport.DialContext != nil, true)
+	assertEqual(t, transport.TLSHandshakeTimeout, 2*time.Second)
+	assertEqual(t, transport.IdleConnTimeout, 3*time.Second)
+	assertEqual(t, transport.MaxIdleConns, 4)
+	assertEqual(t, base.DialContext == nil, true)
+	assertEqual(t, base.MaxIdleConns, 0)
+}
+
+// layer is a TransportWrapper that counts the requests passing through it.
+type layer struct {
+	inner http.RoundTripper
+	calls *int
+}
+
+func (l layer) RoundTrip(r *http.Request) (*http.Response, error) {
+	*l.calls++
+	return l.inner.RoundTrip(r)
+}
+
+func (l layer) Unwrap() http.RoundTripper { return l.inner }
+
+func TestUnwrapTransport(t *testing.T) {
+	base := &http.Transport{}
+	calls := 0
+	wrapped := layer{inner: layer{inner: base, calls: &calls}, calls: &calls}
+
+	transport, ok := trip.UnwrapTransport(wrapped)
+	assertEqual(t, ok, true)
+	assertEqual(t, transport, base)
+
+	_, ok = trip.UnwrapTransport(trip.RoundTripperFunc(noop))
+	assertEqual(t, ok, false)
+}
+
+func TestConfigureThroughWrapper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	base := &http.Transport{}
+	calls := 0
+	wrap := func(next http.RoundTripper) http.RoundTripper {
+		return layer{inner: next, calls: &calls}
+	}
+
+	rt := trip.New(base, wrap, trip.TransportConfig(trip.MaxIdleConns(7)))
+
+	transport, ok := trip.UnwrapTransport(rt)
+	assertEqual(t, ok, true)
+	assertEqual(t, transport, base)
+	assertEqual(t, base.MaxIdleConns, 7)
+
+	client := &http.Client{Transport: rt}
+	get(t, client, server.URL)
+	assertEqual(t, calls, 1)
+}
+
+func TestConfigureThroughWrapperDefaultTransport(t *testing.T) {
+	defer func() {
+		assertEqual(t, recover(), any("trip: Proxy cannot configure http.DefaultTransport in place"))
+	}()
+	calls := 0
+	wrap := func(next http.RoundTripper) http.RoundTripper {
+		return layer{inner: next, calls: &calls}
+	}
+	trip.Default(wrap, trip.Proxy(http.ProxyFromEnvironment))
+}
+
+func TestConfigureThroughSettingsTransport(t *testing.T) {
+	calls := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	tr := trip.NewTransport(&http.Transport{}, trip.TransportSettings{})
+	rt := trip.New(tr, trip.Proxy(http.ProxyURL(proxyURL)))
+	assertEqual(t, rt, http.RoundTripper(tr))
+
+	tr.SetSettings(trip.TransportSettings{RetryAttempts: 3, RetryStatusCodes: []int{http.StatusServiceUnavailable}})
+
+	client := &http.Client{Transport: rt}
+	get(t, client, "http://example.com/")
+	assertEqual(t, calls, 3)
+}
@@ -1,25 +1,20 @@
 package trip
 
 import (
-	"crypto/rand"
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"io"
 	"net/http"
-	"time"
+	"sort"
+	"strings"
 )
 
-// RetryableStatusCodes contains common HTTP status codes
-// that are considered temporary and can be retried.
-var RetryableStatusCodes = []int{
-	http.StatusRequestTimeout,
-	http.StatusTooEarly,
-	http.StatusTooManyRequests,
-	http.StatusInternalServerError,
-	http.StatusBadGateway,
-	http.StatusServiceUnavailable,
-	http.StatusGatewayTimeout,
-}
+// ErrNilResponse is returned in place of a nil response without error from a
+// misbehaving transport.
+var ErrNilResponse = errors.New("trip: nil response with nil error")
 
 // TripFunc is function for wrapping http.RoundTrippers.
 type TripFunc func(http.RoundTripper) http.RoundTripper
@@ -50,6 +45,12 @@ func Default(trips ...TripFunc) http.RoundTripper {
 	return New(nil, trips...)
 }
 
+// Nop is a trip function that returns the transport unchanged. It is useful when
+// building the list of trip functions conditionally.
+func Nop(t http.RoundTripper) http.RoundTripper {
+	return t
+}
+
 // Header sets a header field on every request to the given value.
 func Header(key, value string) TripFunc {
 	return func(t http.RoundTripper) http.RoundTripper {
@@ -60,108 +61,321 @@ func Header(key, value string) TripFunc {
 	}
 }
 
+// HeaderFunc sets a header field on every request to the value returned by f for the
+// request. If f returns an error, the request fails with it without being sent.
+func HeaderFunc(key string, f func(*http.Request) (string, error)) TripFunc {
+	if f == nil {
+		panic("trip: header function is nil")
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			value, err := f(r)
+			if err != nil {
+				return nil, err
+			}
+			r.Header.Set(key, value)
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// HeaderDefault sets a header field to the given value on every request
+// that does not already carry it. Header fields set to an empty value are
+// left untouched.
+func HeaderDefault(key, value string) TripFunc {
+	key = http.CanonicalHeaderKey(key)
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if _, ok := r.Header[key]; !ok {
+				r.Header.Set(key, value)
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// Headers sets all given header fields on every request. Header fields that
+// are not part of h are left untouched.
+func Headers(h http.Header) TripFunc {
+	h = h.Clone()
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			for key, values := range h {
+				r.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// RemoveHeader removes the given header fields from every request.
+func RemoveHeader(keys ...string) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			for _, key := range keys {
+				r.Header.Del(key)
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// StripResponseHeaders removes the given header fields from every response before it
+// is returned, e.g. `Set-Cookie` when proxying responses.
+func StripResponseHeaders(keys ...string) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := t.RoundTrip(r)
+			if resp != nil && resp.Header != nil {
+				for _, key := range keys {
+					resp.Header.Del(key)
+				}
+			}
+			return resp, err
+		})
+	}
+}
+
+// MethodOverride sends requests with one of the given methods as POST requests and
+// sets the `X-HTTP-Method-Override` header to the original method, for servers behind
+// proxies that block methods like PUT or DELETE. The body is sent unchanged.
+func MethodOverride(methods ...string) TripFunc {
+	override := map[string]bool{}
+	for _, method := range methods {
+		override[strings.ToUpper(method)] = true
+	}
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if override[r.Method] {
+				r.Header.Set("X-HTTP-Method-Override", r.Method)
+				r.Method = http.MethodPost
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
 // BearerToken sets the `Authorization` header on every request to `Bearer <token>`.
 func BearerToken(token string) TripFunc {
 	return Header("Authorization", "Bearer "+token)
 }
 
+// BearerTokenForHosts works like BearerToken, but only sets the `Authorization` header
+// on requests to one of the given hosts. Hosts are matched like by AllowHosts, so
+// `*.example.com` matches all subdomains of example.com. Requests to other hosts are
+// sent without the token.
+func BearerTokenForHosts(token string, hosts ...string) TripFunc {
+	match := matchHosts(hosts)
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if match(r.URL.Hostname()) {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// AuthByHost applies the trip function given for the host of a request, e.g. BearerToken
+// or BasicAuth, so that one client can authenticate with several backends. Hosts are
+// matched like by AllowHosts; if several wildcards match, the longest one is used.
+// Requests to hosts without a match are sent as-is.
+func AuthByHost(auth map[string]TripFunc) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		exact := map[string]http.RoundTripper{}
+		var wildcards []string
+		wrapped := map[string]http.RoundTripper{}
+		for host, trip := range auth {
+			host = normalizeHostname(host)
+			if strings.HasPrefix(host, "*.") {
+				wildcards = append(wildcards, host[1:])
+				wrapped[host[1:]] = trip(t)
+			} else {
+				exact[host] = trip(t)
+			}
+		}
+		sort.Slice(wildcards, func(i, j int) bool { return len(wildcards[i]) > len(wildcards[j]) })
+
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			host := normalizeHostname(r.URL.Hostname())
+			if rt, ok := exact[host]; ok {
+				return rt.RoundTrip(r)
+			}
+			for _, suffix := range wildcards {
+				if strings.HasSuffix(host, suffix) {
+					return wrapped[suffix].RoundTrip(r)
+				}
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
 // BasicAuth sets the `Authorization` header on every request to `Basic <encoded-username-and-password>`.
 func BasicAuth(username, password string) TripFunc {
 	encoded := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
 	return Header("Authorization", "Basic "+encoded)
 }
 
+// BasicAuthFunc works like BasicAuth, but the credentials are returned by f for every
+// request, e.g. to use rotating passwords. If f returns an error, the request fails
+// with it without being sent.
+func BasicAuthFunc(f func(*http.Request) (username, password string, err error)) TripFunc {
+	if f == nil {
+		panic("trip: credentials function is nil")
+	}
+	return HeaderFunc("Authorization", func(r *http.Request) (string, error) {
+		username, password, err := f(r)
+		if err != nil {
+			return "", err
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	})
+}
+
 // UserAgent sets the `User-Agent` header on every request to the given user agent.
 func UserAgent(agent string) TripFunc {
 	return Header("User-Agent", agent)
 }
 
+// UserAgentFromContext sets the `User-Agent` header on every request to the string
+// stored under key in the request context. Requests without such a value get the
+// given default user agent instead, unless it is empty.
+func UserAgentFromContext(key any, def string) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if agent, ok := r.Context().Value(key).(string); ok && agent != "" {
+				r.Header.Set("User-Agent", agent)
+			} else if def != "" {
+				r.Header.Set("User-Agent", def)
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// JSON sets the `Accept` header on every request to `application/json`.
+// Requests that carry a body and have no `Content-Type` yet additionally get
+// `Content-Type: application/json`.
+func JSON() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.Header.Set("Accept", "application/json")
+			if hasBody(r) && r.Header.Get("Content-Type") == "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			return t.RoundTrip(r)
+		})
+	}
+}
+
 // IdempotencyKey generates a random string for POST and PATCH requests and sets it
 // as the `Idempotency-Key` header. If used in conjunction with Retry, this
 // function should be applied after Retry.
 func IdempotencyKey() TripFunc {
+	return IdempotencyKeyHeader("Idempotency-Key")
+}
+
+// IdempotencyKeyHeader works like IdempotencyKey, but sets the key as the header with
+// the given name, e.g. `X-Idempotency-Key`.
+func IdempotencyKeyHeader(name string) TripFunc {
 	return func(t http.RoundTripper) http.RoundTripper {
 		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
 			if r.Method == http.MethodPost || r.Method == http.MethodPatch {
-				r.Header.Set("Idempotency-Key", randKey())
+				r.Header.Set(name, randKey())
 			}
 			return t.RoundTrip(r)
 		})
 	}
 }
 
-// Retry retries a failed HTTP request a given number of times and applies a fixed delay
-// inbetween calls. Optionally a list of HTTP status codes can be provided that are
-// considered as failure case.
-// This can be used in combination with RetryableStatusCodes.
-func Retry(attempts int, delay time.Duration, statusCodes ...int) TripFunc {
-	retryable := func(statusCode int) bool {
-		for _, code := range statusCodes {
-			if statusCode == code {
-				return true
+// ContentFingerprint sets the given header on every request to the hex encoded SHA-256
+// of its method, path, sorted query and body. Requests with the same content always
+// carry the same fingerprint, which allows upstreams to deduplicate them. The body is
+// buffered in memory and restored, so the request can still be retried.
+func ContentFingerprint(header string) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			h := sha256.New()
+			io.WriteString(h, r.Method+"\n"+r.URL.EscapedPath()+"\n"+r.URL.Query().Encode()+"\n")
+			if hasBody(r) {
+				body, err := bufferedBytes(r)
+				if err != nil {
+					return nil, err
+				}
+				h.Write(body)
 			}
-		}
-		return false
-	}
-
-	drain := func(resp *http.Response) {
-		if resp == nil || resp.Body == nil {
-			return
-		}
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
+			r.Header.Set(header, hex.EncodeToString(h.Sum(nil)))
+			return t.RoundTrip(r)
+		})
 	}
+}
 
+// AcceptLanguageFromContext sets the `Accept-Language` header on every request to the
+// language tag stored as string under key in the request context. Requests without
+// such a value are left untouched.
+func AcceptLanguageFromContext(key any) TripFunc {
 	return func(t http.RoundTripper) http.RoundTripper {
 		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
-			var resp *http.Response
-			var err error
-
-			for i := 0; i < attempts; i++ {
-				resp, err = t.RoundTrip(r)
-				if err == nil && !retryable(resp.StatusCode) {
-					break
-				}
-				drain(resp)
-				time.Sleep(delay)
+			if lang, ok := r.Context().Value(key).(string); ok && lang != "" {
+				r.Header.Set("Accept-Language", lang)
 			}
-
-			return resp, err
+			return t.RoundTrip(r)
 		})
 	}
 }
 
-// Logger logs every request using the provided log function.
-// Any function that matches the printf signature can be used like log.Printf
-// or similar functions from popular packages like zap, zerolog, logrus, etc.
-// Logger should be placed before Retry in the list of trip functions.
-//
-// Output examples:
-//
-//	POST http://example.com/endpoint?key=value - 200 OK - 12.34ms
-//	POST http://example.com/endpoint?key=value - error:"network error" - 12.34ms
-func Logger(f func(format string, v ...any)) TripFunc {
-	if f == nil {
-		panic("trip: log function is nil")
-	}
+// WithContextValue stores value under key in the context of every request, so that
+// subsequent trip functions and the transport can read it.
+func WithContextValue(key, value any) TripFunc {
 	return func(t http.RoundTripper) http.RoundTripper {
 		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
-			start := time.Now()
+			return t.RoundTrip(r.WithContext(context.WithValue(r.Context(), key, value)))
+		})
+	}
+}
 
-			resp, err := t.RoundTrip(r)
-			if err != nil {
-				f("%s %s - error:%q - %v", r.Method, r.URL.String(), err.Error(), time.Since(start))
-			} else {
-				f("%s %s - %s - %v", r.Method, r.URL.String(), resp.Status, time.Since(start))
-			}
+type disabledKey struct{}
 
-			return resp, err
-		})
+// Disable returns a copy of ctx that makes the trip functions with the given names pass
+// requests carrying it through without acting on them, e.g. to skip retries for a
+// single request sent with a shared client. Retry functions are named "retry" and
+// logging functions "logger".
+func Disable(ctx context.Context, names ...string) context.Context {
+	parent, _ := ctx.Value(disabledKey{}).(map[string]bool)
+	disabled := make(map[string]bool, len(parent)+len(names))
+	for name := range parent {
+		disabled[name] = true
 	}
+	for _, name := range names {
+		disabled[name] = true
+	}
+	return context.WithValue(ctx, disabledKey{}, disabled)
+}
+
+// isDisabled reports whether the trip function with the given name is disabled in ctx
+// by Disable.
+func isDisabled(ctx context.Context, name string) bool {
+	disabled, _ := ctx.Value(disabledKey{}).(map[string]bool)
+	return disabled[name]
+}
+
+// send calls t.RoundTrip and replaces a nil response without error with ErrNilResponse,
+// so that trip functions inspecting the response don't panic.
+func send(t http.RoundTripper, r *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTrip(r)
+	if resp == nil && err == nil {
+		err = ErrNilResponse
+	}
+	return resp, err
+}
+
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody
 }
 
 func randKey() string {
 	var buf [16]byte
-	io.ReadFull(rand.Reader, buf[:])
+	readRand(buf[:])
 	return hex.EncodeToString(buf[:])
 }
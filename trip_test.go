@@ -1,6 +1,7 @@
 package trip_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -43,6 +44,78 @@ func TestHeader(t *testing.T) {
 	}, trip.Header(key, value))
 }
 
+func TestHeaderDefault(t *testing.T) {
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("User-Agent"), "trip")
+		return nil, nil
+	}, trip.HeaderDefault("User-Agent", "trip"))
+}
+
+func TestHeaderDefaultPreserved(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("User-Agent", "custom")
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("User-Agent"), "custom")
+		return nil, nil
+	}), trip.HeaderDefault("User-Agent", "trip")).RoundTrip(req)
+}
+
+func TestHeaderDefaultEmpty(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("User-Agent", "")
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, len(r.Header.Values("User-Agent")), 1)
+		assertEqual(t, r.Header.Get("User-Agent"), "")
+		return nil, nil
+	}), trip.HeaderDefault("user-agent", "trip")).RoundTrip(req)
+}
+
+func TestHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("X-Foo", "foo")
+	headers.Set("X-Bar", "bar")
+	headers.Set("X-Baz", "baz")
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("X-Other", "other")
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("X-Foo"), "foo")
+		assertEqual(t, r.Header.Get("X-Bar"), "bar")
+		assertEqual(t, r.Header.Get("X-Baz"), "baz")
+		assertEqual(t, r.Header.Get("X-Other"), "other")
+		return nil, nil
+	}), trip.Headers(headers)).RoundTrip(req)
+
+	assertEqual(t, len(headers), 3)
+}
+
+func TestRemoveHeader(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("X-Internal", "secret")
+	req.Header.Set("X-Public", "foo")
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("X-Internal"), "")
+		assertEqual(t, r.Header.Get("X-Public"), "foo")
+		return nil, nil
+	}), trip.RemoveHeader("x-internal")).RoundTrip(req)
+}
+
+func TestStripResponseHeaders(t *testing.T) {
+	resp, _ := roundTrip(func(r *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Set-Cookie", "session=secret")
+		header.Set("Content-Type", "text/plain")
+		return &http.Response{StatusCode: 200, Header: header, Body: http.NoBody}, nil
+	}, trip.StripResponseHeaders("set-cookie"))
+
+	assertEqual(t, resp.Header.Get("Set-Cookie"), "")
+	assertEqual(t, resp.Header.Get("Content-Type"), "text/plain")
+}
+
 func TestBearerToken(t *testing.T) {
 	var (
 		token    = "abc123"
@@ -68,6 +141,38 @@ func TestBasicAuth(t *testing.T) {
 	}, trip.BasicAuth(username, password))
 }
 
+func TestBasicAuthFunc(t *testing.T) {
+	passwords := []string{"first", "second"}
+	rotating := func(*http.Request) (string, string, error) {
+		password := passwords[0]
+		passwords = passwords[1:]
+		return "username", password, nil
+	}
+
+	transport := trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		username, password, _ := r.BasicAuth()
+		return &http.Response{StatusCode: 200, Status: username + ":" + password, Body: http.NoBody}, nil
+	}), trip.BasicAuthFunc(rotating))
+
+	for _, expected := range []string{"username:first", "username:second"} {
+		resp, _ := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+		assertEqual(t, resp.Status, expected)
+	}
+}
+
+func TestBasicAuthFuncError(t *testing.T) {
+	errVault := errors.New("vault unavailable")
+	sent := false
+
+	_, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		sent = true
+		return nil, nil
+	}, trip.BasicAuthFunc(func(*http.Request) (string, string, error) { return "", "", errVault }))
+
+	assertEqual(t, err, errVault)
+	assertEqual(t, sent, false)
+}
+
 func TestUserAgent(t *testing.T) {
 	var (
 		userAgent = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
@@ -79,6 +184,32 @@ func TestUserAgent(t *testing.T) {
 	}, trip.UserAgent(userAgent))
 }
 
+func TestJSON(t *testing.T) {
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Accept"), "application/json")
+		assertEqual(t, r.Header.Get("Content-Type"), "")
+		return nil, nil
+	}, trip.JSON())
+}
+
+func TestJSONBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://example.com/foo", strings.NewReader(`{"foo":"bar"}`))
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Accept"), "application/json")
+		assertEqual(t, r.Header.Get("Content-Type"), "application/json")
+		return nil, nil
+	}), trip.JSON()).RoundTrip(req)
+}
+
+func TestJSONKeepsContentType(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://example.com/foo", strings.NewReader(`{"foo":"bar"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Content-Type"), "application/merge-patch+json")
+		return nil, nil
+	}), trip.JSON()).RoundTrip(req)
+}
+
 func TestRetryNetwork(t *testing.T) {
 	var (
 		calls []time.Time
@@ -130,6 +261,113 @@ func TestRetryStatusCodesSkipped(t *testing.T) {
 	assertEqual(t, len(calls), 1)
 }
 
+func TestBearerTokenForHosts(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://api.example.com/v1", expected: "Bearer abc123"},
+		{url: "https://API.example.com:8443/v1", expected: "Bearer abc123"},
+		{url: "https://eu.internal.example.com/", expected: "Bearer abc123"},
+		{url: "https://internal.example.com/", expected: ""},
+		{url: "https://cdn.thirdparty.com/", expected: ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			assertEqual(t, r.Header.Get("Authorization"), tt.expected)
+			return nil, nil
+		}), trip.BearerTokenForHosts("abc123", "api.example.com", "*.internal.example.com")).RoundTrip(req)
+	}
+}
+
+func TestAuthByHost(t *testing.T) {
+	auth := trip.AuthByHost(map[string]trip.TripFunc{
+		"api.example.com":     trip.BearerToken("abc123"),
+		"*.legacy.example":    trip.BasicAuth("user", "pass"),
+		"*.eu.legacy.example": trip.Header("Authorization", "Token eu"),
+	})
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://api.example.com/", expected: "Bearer abc123"},
+		{url: "https://us.legacy.example/", expected: "Basic dXNlcjpwYXNz"},
+		{url: "https://de.eu.legacy.example/", expected: "Token eu"},
+		{url: "https://other.example.com/", expected: ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			assertEqual(t, r.Header.Get("Authorization"), tt.expected)
+			return nil, nil
+		}), auth).RoundTrip(req)
+	}
+}
+
+func TestHeaderFunc(t *testing.T) {
+	signature := func(r *http.Request) (string, error) {
+		return "path=" + r.URL.Path, nil
+	}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("X-Signature"), "path=/foo")
+		return nil, nil
+	}, trip.HeaderFunc("X-Signature", signature))
+}
+
+func TestHeaderFuncError(t *testing.T) {
+	errSign := errors.New("no key")
+	sent := false
+
+	_, err := roundTrip(func(r *http.Request) (*http.Response, error) {
+		sent = true
+		return nil, nil
+	}, trip.HeaderFunc("X-Signature", func(*http.Request) (string, error) { return "", errSign }))
+
+	assertEqual(t, err, errSign)
+	assertEqual(t, sent, false)
+}
+
+func TestMethodOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s %d", r.Method, r.Header.Get("X-HTTP-Method-Override"), body, r.ContentLength)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: trip.Default(trip.MethodOverride("PUT", "delete"))}
+	send := func(method, body string) string {
+		req, _ := http.NewRequest(method, server.URL, strings.NewReader(body))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	assertEqual(t, send("DELETE", ""), "POST DELETE  0")
+	assertEqual(t, send("PUT", "hello"), "POST PUT hello 5")
+	assertEqual(t, send("PATCH", "hello"), "PATCH  hello 5")
+}
+
+func TestNop(t *testing.T) {
+	transport := trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 204, Body: http.NoBody}, nil
+	})
+	wrapped := trip.New(transport, trip.Nop)
+
+	resp, err := wrapped.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.StatusCode, 204)
+	assertEqual(t, fmt.Sprintf("%p", wrapped), fmt.Sprintf("%p", transport))
+}
+
 func TestIdempotencyKey(t *testing.T) {
 	var (
 		idems []string
@@ -148,6 +386,90 @@ func TestIdempotencyKey(t *testing.T) {
 	assertEqual(t, idems[0], idems[1])
 }
 
+func TestIdempotencyKeyHeader(t *testing.T) {
+	var idems []string
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Idempotency-Key"), "")
+		idems = append(idems, r.Header.Get("X-Idempotency-Key"))
+		return nil, errors.New("network error")
+	}, trip.Retry(3, time.Millisecond), trip.IdempotencyKeyHeader("X-Idempotency-Key"))
+
+	assertEqual(t, len(idems), 3)
+	assertNotEqual(t, idems[0], "")
+	assertEqual(t, idems[1], idems[0])
+	assertEqual(t, idems[2], idems[0])
+}
+
+func TestWithContextValue(t *testing.T) {
+	type tenantKey struct{}
+
+	roundTrip(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Context().Value(tenantKey{}), any("acme"))
+		return nil, nil
+	}, trip.WithContextValue(tenantKey{}, "acme"))
+}
+
+func TestUserAgentFromContext(t *testing.T) {
+	type agentKey struct{}
+
+	userAgent := func(ctx context.Context) string {
+		var got string
+		req := httptest.NewRequest("GET", "http://example.com/", nil).WithContext(ctx)
+		trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			got = r.Header.Get("User-Agent")
+			return nil, nil
+		}), trip.UserAgentFromContext(agentKey{}, "default/1.0")).RoundTrip(req)
+		return got
+	}
+
+	assertEqual(t, userAgent(context.WithValue(context.Background(), agentKey{}, "library/2.0")), "library/2.0")
+	assertEqual(t, userAgent(context.Background()), "default/1.0")
+}
+
+func TestContentFingerprint(t *testing.T) {
+	fingerprint := func(target, body string) string {
+		var got string
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			got = r.Header.Get("X-Fingerprint")
+			b, _ := io.ReadAll(r.Body)
+			assertEqual(t, string(b), body)
+			return nil, nil
+		}), trip.ContentFingerprint("X-Fingerprint")).RoundTrip(req)
+		return got
+	}
+
+	a := fingerprint("http://example.com/foo?b=2&a=1", "hello")
+	assertEqual(t, len(a), 64)
+	assertEqual(t, fingerprint("http://example.com/foo?a=1&b=2", "hello"), a)
+	assertNotEqual(t, fingerprint("http://example.com/foo?a=1&b=2", "world"), a)
+}
+
+func TestAcceptLanguageFromContext(t *testing.T) {
+	type langKey struct{}
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), langKey{}, "de-CH"))
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Accept-Language"), "de-CH")
+		return nil, nil
+	}), trip.AcceptLanguageFromContext(langKey{})).RoundTrip(req)
+}
+
+func TestAcceptLanguageFromContextAbsent(t *testing.T) {
+	type langKey struct{}
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Accept-Language", "en")
+
+	trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assertEqual(t, r.Header.Get("Accept-Language"), "en")
+		return nil, nil
+	}), trip.AcceptLanguageFromContext(langKey{})).RoundTrip(req)
+}
+
 func TestLogger(t *testing.T) {
 	logf := func(format string, v ...any) {
 		msg := fmt.Sprintf(format, v...)
@@ -170,10 +492,10 @@ func TestLoggerError(t *testing.T) {
 	}, trip.Logger(logf))
 }
 
-func roundTrip(f trip.RoundTripperFunc, trips ...trip.TripFunc) {
+func roundTrip(f trip.RoundTripperFunc, trips ...trip.TripFunc) (*http.Response, error) {
 	req := httptest.NewRequest("POST", "http://example.com/foo?bar=yes", nil)
 	transport := trip.New(f, trips...)
-	transport.RoundTrip(req)
+	return transport.RoundTrip(req)
 }
 
 func noop(r *http.Request) (*http.Response, error) {
@@ -192,6 +514,12 @@ func assertPrefix(t *testing.T, a, b string) {
 	}
 }
 
+func assertSuffix(t *testing.T, a, b string) {
+	if !strings.HasSuffix(a, b) {
+		t.Errorf("got: %v, expected to end with: %v", a, b)
+	}
+}
+
 func assertNotEqual[T comparable](t *testing.T, a T, b T) {
 	if a == b {
 		t.Errorf("got: %v and %v, expected something else", a, b)
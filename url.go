@@ -0,0 +1,42 @@
+package trip
+
+import (
+	"net/http"
+	"strings"
+)
+
+// NormalizeHost removes default ports (80 for http, 443 for https) from the URL host
+// and `Host` header of every request. Other ports are preserved.
+func NormalizeHost() TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.URL.Host = stripDefaultPort(r.URL.Scheme, r.URL.Host)
+			r.Host = stripDefaultPort(r.URL.Scheme, r.Host)
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+// OverrideHost sends every request to urlHost, while the `Host` header is set to
+// hostHeader. This allows talking to a specific server, e.g. a single pod behind a
+// load balancer, with the logical hostname preserved. Note that for https the TLS
+// server name is derived from urlHost, unless configured on the transport.
+func OverrideHost(urlHost, hostHeader string) TripFunc {
+	return func(t http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.URL.Host = urlHost
+			r.Host = hostHeader
+			return t.RoundTrip(r)
+		})
+	}
+}
+
+func stripDefaultPort(scheme, host string) string {
+	switch strings.ToLower(scheme) {
+	case "http":
+		return strings.TrimSuffix(host, ":80")
+	case "https":
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
@@ -0,0 +1,51 @@
+package trip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/philippta/trip"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://example.com:443/foo", expected: "example.com"},
+		{url: "http://example.com:80/foo", expected: "example.com"},
+		{url: "https://example.com:8443/foo", expected: "example.com:8443"},
+		{url: "http://example.com:443/foo", expected: "example.com:443"},
+		{url: "https://[::1]:443/foo", expected: "[::1]"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+
+		trip.New(trip.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			assertEqual(t, r.URL.Host, tt.expected)
+			assertEqual(t, r.Host, tt.expected)
+			return nil, nil
+		}), trip.NormalizeHost()).RoundTrip(req)
+	}
+}
+
+func TestOverrideHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, r.Host, "logical.example.com")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: trip.Default(trip.OverrideHost(serverURL.Host, "logical.example.com"))}
+
+	resp, err := client.Get("http://unreachable.invalid/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assertEqual(t, resp.StatusCode, http.StatusNoContent)
+}